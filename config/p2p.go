@@ -38,6 +38,11 @@ type P2P struct {
 
 	// Enable AutoNAT service. unless you're hosting a server, leave this as false
 	AutoNAT bool `json:"autoNAT"`
+
+	// MaxResolveHandlers caps the number of reference resolution requests from
+	// peers this node handles at once. Requests beyond the cap are rejected as
+	// busy. Zero uses the default limit
+	MaxResolveHandlers int `json:"maxresolvehandlers,omitempty"`
 }
 
 // SetArbitrary is an interface implementation of base/fill/struct in order to safely
@@ -144,6 +149,11 @@ func (cfg P2P) Validate() error {
         "items": {
           "type": "string"
         }
+      },
+      "maxresolvehandlers": {
+        "description": "Maximum number of reference resolution requests from peers to handle at once. Zero uses the default",
+        "type": "integer",
+        "minimum": 0
       }
     }
   }`)
@@ -157,6 +167,8 @@ func (cfg *P2P) Copy() *P2P {
 		PeerID:  cfg.PeerID,
 		PrivKey: cfg.PrivKey,
 		Port:    cfg.Port,
+
+		MaxResolveHandlers: cfg.MaxResolveHandlers,
 	}
 
 	if cfg.QriBootstrapAddrs != nil {
@@ -48,6 +48,28 @@ func BuildDscacheFromLogbookAndProfilesAndDsref(ctx context.Context, refs []repo
 	return buildDscacheFlatbuffer(userProfileList, entryInfoList), nil
 }
 
+// buildDscacheFromLogbook creates a dscache from a logbook alone, for when
+// refs & profiles aren't available. Users are named by their logbook names
+func buildDscacheFromLogbook(ctx context.Context, book *logbook.Book) (*Dscache, error) {
+	userLogs, err := book.ListAllLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userProfileList := make([]userProfilePair, 0, len(userLogs))
+	for _, userLog := range userLogs {
+		if len(userLog.Ops) < 1 {
+			continue
+		}
+		userProfileList = append(userProfileList, userProfilePair{Username: userLog.Name(), ProfileID: userLog.Ops[0].AuthorID})
+	}
+
+	entryInfoList, err := convertLogbookAndRefs(ctx, book, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buildDscacheFlatbuffer(userProfileList, entryInfoList), nil
+}
+
 type userProfilePair struct {
 	Username  string
 	ProfileID string
@@ -137,7 +159,7 @@ func buildDscacheFlatbuffer(userPairList []userProfilePair, entryInfoList []*ent
 	builder.Finish(cache)
 	serialized := builder.FinishedBytes()
 	root := dscachefb.GetRootAsDscache(serialized, 0)
-	return &Dscache{Root: root, Buffer: serialized}
+	return &Dscache{root: root, buffer: serialized}
 }
 
 // entryInfo is a VersionInfo plus the position that maps it to the logbook's structure. Maps
@@ -208,6 +230,11 @@ func convertLogbookUserToDsInfoList(profileID string, dsLogs []*oplog.Log) []*en
 }
 
 func convertDatasetHistoryToDsInfo(dsLog oplog.Log) *entryInfo {
+	// Soft-deleted datasets are excluded
+	if logbook.DatasetDeleted(&dsLog) {
+		return nil
+	}
+
 	// Get the final pretty name, most recently ammended.
 	prettyName := ""
 	for _, op := range dsLog.Ops {
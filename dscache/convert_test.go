@@ -419,6 +419,44 @@ func TestConvertLogbookAndRefsWithNoHistoryDatasetAndDeletedDataset(t *testing.T
 	}
 }
 
+func TestConvertLogbookAndRefsSoftDeletedDataset(t *testing.T) {
+	run := NewDscacheTestRunner()
+	defer run.Delete()
+
+	ctx := context.Background()
+
+	peerInfo := testPeers.GetTestPeerInfo(0)
+	book := makeFakeLogbook(ctx, t, "test_user", peerInfo.PrivKey)
+
+	countInfos := func() int {
+		entryInfoList, err := convertLogbookAndRefs(ctx, book, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entryInfoList)
+	}
+
+	numDatasets := countInfos()
+	initID, err := book.RefToInitID(dsref.Ref{Username: "test_user", Name: "second_name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := book.WriteDatasetSoftDelete(ctx, initID); err != nil {
+		t.Fatal(err)
+	}
+	if got := countInfos(); got != numDatasets-1 {
+		t.Errorf("expected soft-deleted dataset to be excluded. expected %d datasets, got: %d", numDatasets-1, got)
+	}
+
+	if err := book.WriteDatasetUndelete(ctx, initID); err != nil {
+		t.Fatal(err)
+	}
+	if got := countInfos(); got != numDatasets {
+		t.Errorf("expected undeleted dataset to be listed. expected %d datasets, got: %d", numDatasets, got)
+	}
+}
+
 // Test the top-level build function, and that the references are alphabetized
 func TestBuildDscacheFromLogbookAndProfilesAndDsrefAlphabetized(t *testing.T) {
 	run := NewDscacheTestRunner()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"time"
 
 	flatbuffers "github.com/google/flatbuffers/go"
@@ -14,6 +15,7 @@ import (
 	"github.com/qri-io/qri/dscache/dscachefb"
 	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/logbook"
 	"github.com/qri-io/qri/repo/profile"
 	reporef "github.com/qri-io/qri/repo/ref"
 )
@@ -29,29 +31,55 @@ var (
 )
 
 // Dscache represents an in-memory serialized dscache flatbuffer
+// Dscache is safe for concurrent use. Flatbuffers are immutable, so changes
+// build an entirely new flatbuffer that is swapped in once complete. Readers
+// use a snapshot of the flatbuffer for the duration of a read, and always see
+// a consistent version of the cache
 type Dscache struct {
-	Filename            string
-	Root                *dscachefb.Dscache
-	Buffer              []byte
-	CreateNewEnabled    bool
-	ProfileIDToUsername map[string]string
-	DefaultUsername     string
+	Filename         string
+	CreateNewEnabled bool
+	DefaultUsername  string
+
+	// root & buffer are the current snapshot, read with the Root & Buffer
+	// accessors
+	root   *dscachefb.Dscache
+	buffer []byte
+	// lk guards swapping root & buffer
+	lk sync.RWMutex
+	// updateLk serializes updates, so concurrent changes aren't lost
+	updateLk sync.Mutex
+}
+
+// Options configures a Dscache
+type Options struct {
+	// Logbook is used to rebuild the cache when the cache file is corrupt.
+	// Without a logbook a corrupt cache file is ignored, starting empty
+	Logbook *logbook.Book
 }
 
 // NewDscache will construct a dscache from the given filename, or will construct an empty dscache
-// that will save to the given filename. Using an empty filename will disable loading and saving
-func NewDscache(ctx context.Context, fsys qfs.Filesystem, bus event.Bus, username, filename string) *Dscache {
-	cache := Dscache{Filename: filename}
+// that will save to the given filename. Using an empty filename will disable loading and saving.
+// A corrupt cache file is never an error, the cache is rebuilt from the logbook option instead
+func NewDscache(ctx context.Context, fsys qfs.Filesystem, bus event.Bus, username, filename string, opts ...func(o *Options)) *Dscache {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cache := &Dscache{Filename: filename}
 	f, err := fsys.Get(ctx, filename)
 	if err == nil {
 		// Ignore error, as dscache loading is optional
 		defer f.Close()
-		buffer, err := ioutil.ReadAll(f)
+		data, err := ioutil.ReadAll(f)
 		if err != nil {
 			log.Error(err)
+		} else if buffer, err := decodeFile(data); err != nil {
+			log.Warnf("ignoring dscache file %q: %s", filename, err)
+			cache.rebuild(ctx, o.Logbook)
 		} else {
-			root := dscachefb.GetRootAsDscache(buffer, 0)
-			cache = Dscache{Filename: filename, Root: root, Buffer: buffer}
+			cache.root = dscachefb.GetRootAsDscache(buffer, 0)
+			cache.buffer = buffer
 		}
 	}
 	cache.DefaultUsername = username
@@ -62,7 +90,7 @@ func NewDscache(ctx context.Context, fsys qfs.Filesystem, bus event.Bus, usernam
 		event.ETDatasetRename,
 		event.ETDatasetCreateLink)
 
-	return &cache
+	return cache
 }
 
 // IsEmpty returns whether the dscache has any constructed data in it
@@ -70,7 +98,53 @@ func (d *Dscache) IsEmpty() bool {
 	if d == nil {
 		return true
 	}
-	return d.Root == nil
+	return d.snapshot() == nil
+}
+
+// snapshot returns the current flatbuffer root. The returned root is
+// immutable, and is unaffected by later updates
+func (d *Dscache) snapshot() *dscachefb.Dscache {
+	d.lk.RLock()
+	defer d.lk.RUnlock()
+	return d.root
+}
+
+// Root returns the current flatbuffer root, or nil if the cache is empty. The
+// returned root is immutable, and is unaffected by later updates
+func (d *Dscache) Root() *dscachefb.Dscache {
+	if d == nil {
+		return nil
+	}
+	return d.snapshot()
+}
+
+// Buffer returns the serialized flatbuffer of the current root. Callers must
+// not modify the returned bytes
+func (d *Dscache) Buffer() []byte {
+	if d == nil {
+		return nil
+	}
+	d.lk.RLock()
+	defer d.lk.RUnlock()
+	return d.buffer
+}
+
+// ProfileIDToUsername maps the profileIDs of users in the cache to their
+// usernames
+func (d *Dscache) ProfileIDToUsername() map[string]string {
+	if d.IsEmpty() {
+		return map[string]string{}
+	}
+	return profileIDToUsernameMap(d.snapshot())
+}
+
+// publish atomically swaps in a new version of the cache, then saves it
+func (d *Dscache) publish(root *dscachefb.Dscache, buffer []byte) error {
+	d.lk.Lock()
+	d.root = root
+	d.buffer = buffer
+	d.lk.Unlock()
+	return d.save(buffer)
 }
 
 // Assign assigns the data from one dscache to this one
@@ -78,9 +152,10 @@ func (d *Dscache) Assign(other *Dscache) error {
 	if d == nil {
 		return ErrNoDscache
 	}
-	d.Root = other.Root
-	d.Buffer = other.Buffer
-	return d.save()
+	other.lk.RLock()
+	root, buffer := other.root, other.buffer
+	other.lk.RUnlock()
+	return d.publish(root, buffer)
 }
 
 // VerboseString is a convenience function that returns a readable string, for testing and debugging
@@ -88,20 +163,21 @@ func (d *Dscache) VerboseString(showEmpty bool) string {
 	if d.IsEmpty() {
 		return "dscache: cannot not stringify an empty dscache"
 	}
+	root := d.snapshot()
 	out := strings.Builder{}
 	out.WriteString("Dscache:\n")
 	out.WriteString(" Dscache.Users:\n")
-	for i := 0; i < d.Root.UsersLength(); i++ {
+	for i := 0; i < root.UsersLength(); i++ {
 		userAssoc := dscachefb.UserAssoc{}
-		d.Root.Users(&userAssoc, i)
+		root.Users(&userAssoc, i)
 		username := userAssoc.Username()
 		profileID := userAssoc.ProfileID()
 		fmt.Fprintf(&out, " %2d) user=%s profileID=%s\n", i, username, profileID)
 	}
 	out.WriteString(" Dscache.Refs:\n")
-	for i := 0; i < d.Root.RefsLength(); i++ {
+	for i := 0; i < root.RefsLength(); i++ {
 		r := dscachefb.RefEntryInfo{}
-		d.Root.Refs(&r, i)
+		root.Refs(&r, i)
 		fmt.Fprintf(&out, ` %2d) initID        = %s
      profileID     = %s
      topIndex      = %d
@@ -142,18 +218,19 @@ func (d *Dscache) ListRefs() ([]reporef.DatasetRef, error) {
 	if d.IsEmpty() {
 		return nil, ErrNoDscache
 	}
-	d.ensureProToUserMap()
-	refs := make([]reporef.DatasetRef, 0, d.Root.RefsLength())
-	for i := 0; i < d.Root.RefsLength(); i++ {
+	root := d.snapshot()
+	profileIDToUsername := profileIDToUsernameMap(root)
+	refs := make([]reporef.DatasetRef, 0, root.RefsLength())
+	for i := 0; i < root.RefsLength(); i++ {
 		refCache := dscachefb.RefEntryInfo{}
-		d.Root.Refs(&refCache, i)
+		root.Refs(&refCache, i)
 
 		proIDStr := string(refCache.ProfileID())
 		profileID, err := profile.NewB58ID(proIDStr)
 		if err != nil {
 			log.Errorf("could not parse profileID %q", proIDStr)
 		}
-		username, ok := d.ProfileIDToUsername[proIDStr]
+		username, ok := profileIDToUsername[proIDStr]
 		if !ok {
 			log.Errorf("no username associated with profileID %q", proIDStr)
 		}
@@ -189,6 +266,10 @@ func (d *Dscache) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error
 		return "", dsref.ErrRefNotFound
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	vi, err := d.LookupByName(*ref)
 	if err != nil {
 		return "", dsref.ErrRefNotFound
@@ -205,10 +286,14 @@ func (d *Dscache) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error
 
 // LookupByName looks up a dataset by dsref and returns the latest VersionInfo if found
 func (d *Dscache) LookupByName(ref dsref.Ref) (*dsref.VersionInfo, error) {
+	root := d.snapshot()
+	if root == nil {
+		return nil, ErrNoDscache
+	}
 	// Convert the username into a profileID
-	for i := 0; i < d.Root.UsersLength(); i++ {
+	for i := 0; i < root.UsersLength(); i++ {
 		userAssoc := dscachefb.UserAssoc{}
-		d.Root.Users(&userAssoc, i)
+		root.Users(&userAssoc, i)
 		username := userAssoc.Username()
 		profileID := userAssoc.ProfileID()
 		if ref.Username == string(username) {
@@ -222,9 +307,9 @@ func (d *Dscache) LookupByName(ref dsref.Ref) (*dsref.VersionInfo, error) {
 		return nil, fmt.Errorf("unknown username %q", ref.Username)
 	}
 	// Lookup the info, given the profileID/dsname
-	for i := 0; i < d.Root.RefsLength(); i++ {
+	for i := 0; i < root.RefsLength(); i++ {
 		r := dscachefb.RefEntryInfo{}
-		d.Root.Refs(&r, i)
+		root.Refs(&r, i)
 		if string(r.ProfileID()) == ref.ProfileID && string(r.PrettyName()) == ref.Name {
 			info := convertEntryToVersionInfo(&r)
 			return &info, nil
@@ -269,7 +354,14 @@ func (d *Dscache) handler(_ context.Context, t event.Type, payload interface{})
 }
 
 func (d *Dscache) updateInitDataset(act event.DsChange) error {
-	if d.IsEmpty() {
+	if d == nil {
+		return ErrNoDscache
+	}
+	d.updateLk.Lock()
+	defer d.updateLk.Unlock()
+
+	root := d.snapshot()
+	if root == nil {
 		// Only create a new dscache if that feature is enabled. This way no one is forced to
 		// use dscache without opting in.
 		if !d.CreateNewEnabled {
@@ -288,20 +380,19 @@ func (d *Dscache) updateInitDataset(act event.DsChange) error {
 			Name:      act.PrettyName,
 		})
 		cache := builder.Build()
-		d.Assign(cache)
-		return nil
+		return d.publish(cache.root, cache.buffer)
 	}
 	builder := NewBuilder()
 	// copy users
-	for i := 0; i < d.Root.UsersLength(); i++ {
+	for i := 0; i < root.UsersLength(); i++ {
 		up := dscachefb.UserAssoc{}
-		d.Root.Users(&up, i)
+		root.Users(&up, i)
 		builder.AddUser(string(up.Username()), string(up.ProfileID()))
 	}
 	// copy ds versions
-	for i := 0; i < d.Root.UsersLength(); i++ {
+	for i := 0; i < root.UsersLength(); i++ {
 		r := dscachefb.RefEntryInfo{}
-		d.Root.Refs(&r, i)
+		root.Refs(&r, i)
 		builder.AddDsVersionInfoWithIndexes(convertEntryToVersionInfo(&r), int(r.TopIndex()), int(r.CursorIndex()))
 	}
 	// Add new ds version info
@@ -311,20 +402,27 @@ func (d *Dscache) updateInitDataset(act event.DsChange) error {
 		Name:      act.PrettyName,
 	})
 	cache := builder.Build()
-	d.Assign(cache)
-	return nil
+	return d.publish(cache.root, cache.buffer)
 }
 
 // Copy the entire dscache, except for the matching entry, rebuild that one to modify it
 func (d *Dscache) updateChangeCursor(act event.DsChange) error {
-	if d.IsEmpty() {
+	if d == nil {
+		return ErrNoDscache
+	}
+	d.updateLk.Lock()
+	defer d.updateLk.Unlock()
+
+	root := d.snapshot()
+	if root == nil {
 		return ErrNoDscache
 	}
 	// Flatbuffers for go do not allow mutation (for complex types like strings). So we construct
 	// a new flatbuffer entirely, copying the old one while replacing the entry we care to change.
 	builder := flatbuffers.NewBuilder(0)
-	users := d.copyUserAssociationList(builder)
+	users := d.copyUserAssociationList(root, builder)
 	refs := d.copyReferenceListWithReplacement(
+		root,
 		builder,
 		// Function to match the entry we're looking to replace
 		func(r *dscachefb.RefEntryInfo) bool {
@@ -353,22 +451,27 @@ func (d *Dscache) updateChangeCursor(act event.DsChange) error {
 			// Don't call RefEntryInfoEnd, that is handled by copyReferenceListWithReplacement
 		},
 	)
-	root, serialized := d.finishBuilding(builder, users, refs)
-	d.Root = root
-	d.Buffer = serialized
-	return d.save()
+	return d.publish(d.finishBuilding(builder, users, refs))
 }
 
 // Copy the entire dscache, except leave out the matching entry.
 func (d *Dscache) updateDeleteDataset(act event.DsChange) error {
-	if d.IsEmpty() {
+	if d == nil {
+		return ErrNoDscache
+	}
+	d.updateLk.Lock()
+	defer d.updateLk.Unlock()
+
+	root := d.snapshot()
+	if root == nil {
 		return ErrNoDscache
 	}
 	// Flatbuffers for go do not allow mutation (for complex types like strings). So we construct
 	// a new flatbuffer entirely, copying the old one while omitting the entry we want to remove.
 	builder := flatbuffers.NewBuilder(0)
-	users := d.copyUserAssociationList(builder)
+	users := d.copyUserAssociationList(root, builder)
 	refs := d.copyReferenceListWithReplacement(
+		root,
 		builder,
 		func(r *dscachefb.RefEntryInfo) bool {
 			return string(r.InitID()) == act.InitID
@@ -376,22 +479,27 @@ func (d *Dscache) updateDeleteDataset(act event.DsChange) error {
 		// Pass a nil function, so the matching entry is not replaced, it is omitted
 		nil,
 	)
-	root, serialized := d.finishBuilding(builder, users, refs)
-	d.Root = root
-	d.Buffer = serialized
-	return d.save()
+	return d.publish(d.finishBuilding(builder, users, refs))
 }
 
 // Copy the entire dscache, except for the matching entry, which is copied then assigned an fsiPath
 func (d *Dscache) updateCreateLink(act event.DsChange) error {
-	if d.IsEmpty() {
+	if d == nil {
+		return ErrNoDscache
+	}
+	d.updateLk.Lock()
+	defer d.updateLk.Unlock()
+
+	root := d.snapshot()
+	if root == nil {
 		return ErrNoDscache
 	}
 	// Flatbuffers for go do not allow mutation (for complex types like strings). So we construct
 	// a new flatbuffer entirely, copying the old one while replacing the entry we care to change.
 	builder := flatbuffers.NewBuilder(0)
-	users := d.copyUserAssociationList(builder)
+	users := d.copyUserAssociationList(root, builder)
 	refs := d.copyReferenceListWithReplacement(
+		root,
 		builder,
 		// Function to match the entry we're looking to replace
 		func(r *dscachefb.RefEntryInfo) bool {
@@ -410,10 +518,7 @@ func (d *Dscache) updateCreateLink(act event.DsChange) error {
 			// Don't call RefEntryInfoEnd, that is handled by copyReferenceListWithReplacement
 		},
 	)
-	root, serialized := d.finishBuilding(builder, users, refs)
-	d.Root = root
-	d.Buffer = serialized
-	return d.save()
+	return d.publish(d.finishBuilding(builder, users, refs))
 }
 
 func convertEntryToVersionInfo(r *dscachefb.RefEntryInfo) dsref.VersionInfo {
@@ -436,25 +541,39 @@ func convertEntryToVersionInfo(r *dscachefb.RefEntryInfo) dsref.VersionInfo {
 	}
 }
 
-func (d *Dscache) ensureProToUserMap() {
-	if d.ProfileIDToUsername != nil {
-		return
-	}
-	d.ProfileIDToUsername = make(map[string]string)
-	for i := 0; i < d.Root.UsersLength(); i++ {
+func profileIDToUsernameMap(root *dscachefb.Dscache) map[string]string {
+	m := make(map[string]string)
+	for i := 0; i < root.UsersLength(); i++ {
 		userAssoc := dscachefb.UserAssoc{}
-		d.Root.Users(&userAssoc, i)
+		root.Users(&userAssoc, i)
 		username := userAssoc.Username()
 		profileID := userAssoc.ProfileID()
-		d.ProfileIDToUsername[string(profileID)] = string(username)
+		m[string(profileID)] = string(username)
 	}
+	return m
 }
 
-// save writes the serialized bytes to the given filename
-func (d *Dscache) save() error {
+// save writes serialized bytes to the given filename
+func (d *Dscache) save(buffer []byte) error {
 	if d.Filename == "" {
 		log.Infof("dscache: no filename set, will not save")
 		return nil
 	}
-	return ioutil.WriteFile(d.Filename, d.Buffer, 0644)
+	return ioutil.WriteFile(d.Filename, encodeFile(buffer), 0644)
+}
+
+// rebuild replaces the contents of the cache with a cache built from a
+// logbook. rebuild leaves the cache empty if book is nil
+func (d *Dscache) rebuild(ctx context.Context, book *logbook.Book) {
+	if book == nil {
+		return
+	}
+	built, err := buildDscacheFromLogbook(ctx, book)
+	if err != nil {
+		log.Errorf("rebuilding dscache from logbook: %s", err)
+		return
+	}
+	if err := d.publish(built.root, built.buffer); err != nil {
+		log.Errorf("saving rebuilt dscache: %s", err)
+	}
 }
@@ -2,10 +2,12 @@ package dscache
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/qri-io/qfs"
@@ -73,14 +75,103 @@ func TestDscacheAssignSaveAndLoad(t *testing.T) {
 
 	// Load the dscache from its serialized file, verify it has correct data
 	loadable := NewDscache(ctx, fs, event.NilBus, peername, dscacheFile)
-	if loadable.Root.UsersLength() != 1 {
-		t.Errorf("expected, 1 user, got %d users", loadable.Root.UsersLength())
+	if loadable.Root().UsersLength() != 1 {
+		t.Errorf("expected, 1 user, got %d users", loadable.Root().UsersLength())
 	}
-	if loadable.Root.RefsLength() != 2 {
-		t.Errorf("expected, 2 refs, got %d refs", loadable.Root.RefsLength())
+	if loadable.Root().RefsLength() != 2 {
+		t.Errorf("expected, 2 refs, got %d refs", loadable.Root().RefsLength())
+	}
+	proID := profile.IDFromPeerID(peerInfo.PeerID).String()
+	if got := loadable.ProfileIDToUsername()[proID]; got != peername {
+		t.Errorf("expected profileID to map to %q, got %q", peername, got)
 	}
 }
 
+func TestNewDscacheCorruptFile(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ctx := context.Background()
+	fs, err := localfs.NewFS(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	username, dsName := "corrupt_cache_user", "example"
+	book, err := logbook.NewJournal(testPeers.GetTestPeerInfo(0).PrivKey, username, event.NilBus, qfs.NewMemFS(), "/mem/logbook.qfb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dsrefspec.GenerateExampleOplog(ctx, book, dsName, "/ipfs/QmExample"); err != nil {
+		t.Fatal(err)
+	}
+	withLogbook := func(o *Options) { o.Logbook = book }
+
+	valid := NewBuilder()
+	valid.AddUser(username, "profile_id")
+	validBuffer := valid.Build().Buffer()
+
+	cases := []struct {
+		description string
+		data        []byte
+	}{
+		{"garbage", []byte("not a flatbuffer at all")},
+		{"truncated", validBuffer[:len(validBuffer)/2]},
+		{"checksum mismatch", append(encodeFile(validBuffer)[:fileHeaderLen], validBuffer[1:]...)},
+		{"flipped byte", flipLastByte(encodeFile(validBuffer))},
+		{"unsupported version", append(append(append([]byte{}, fileMagic...), fileVersion+1), encodeFile(validBuffer)[len(fileMagic)+1:]...)},
+	}
+
+	for _, c := range cases {
+		path := filepath.Join(tmpdir, "dscache.qfb")
+		if err := ioutil.WriteFile(path, c.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// without a logbook a corrupt cache starts empty
+		if dsc := NewDscache(ctx, fs, event.NilBus, username, path); !dsc.IsEmpty() {
+			t.Errorf("%s: expected corrupt cache without a logbook to be empty", c.description)
+		}
+
+		dsc := NewDscache(ctx, fs, event.NilBus, username, path, withLogbook)
+		ref := dsref.Ref{Username: username, Name: dsName}
+		if _, err := dsc.ResolveRef(ctx, &ref); err != nil {
+			t.Errorf("%s: expected rebuilt cache to resolve dataset, got: %s", c.description, err)
+			continue
+		}
+		if ref.Path != "/ipfs/QmExample" {
+			t.Errorf("%s: expected rebuilt cache to resolve head path, got: %q", c.description, ref.Path)
+		}
+
+		// the rebuilt cache is saved, replacing the corrupt file
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodeFile(data); err != nil {
+			t.Errorf("%s: expected rebuilt cache file to be valid, got: %s", c.description, err)
+		}
+	}
+
+	// files written before the header was introduced still load
+	path := filepath.Join(tmpdir, "dscache.qfb")
+	if err := ioutil.WriteFile(path, validBuffer, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if dsc := NewDscache(ctx, fs, event.NilBus, username, path); dsc.IsEmpty() || dsc.Root().UsersLength() != 1 {
+		t.Errorf("expected headerless cache file to load")
+	}
+}
+
+// flipLastByte inverts the final byte of data, leaving its length unchanged
+func flipLastByte(data []byte) []byte {
+	data[len(data)-1] ^= 0xff
+	return data
+}
+
 func TestResolveRef(t *testing.T) {
 	tmpdir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -96,7 +187,7 @@ func TestResolveRef(t *testing.T) {
 	path := filepath.Join(tmpdir, "dscache.qfb")
 	dsc := NewDscache(ctx, fs, event.NilBus, "test_resolve_ref_user", path)
 
-	dsrefspec.AssertResolverSpec(t, dsc, func(r dsref.Ref, author identity.Author, _ *oplog.Log) error {
+	dsrefspec.AssertResolverSpec(t, dsc, func(_ context.Context, r dsref.Ref, author identity.Author, _ *oplog.Log) error {
 		builder := NewBuilder()
 		pid, err := identity.KeyIDFromPub(author.AuthorPubKey())
 		builder.AddUser(r.Username, pid)
@@ -164,3 +255,77 @@ func TestCacheRefConsistency(t *testing.T) {
 		t.Errorf("inconsistent resolution between dscache & logbook:\n%s", err)
 	}
 }
+
+func TestDscacheConcurrentRebuild(t *testing.T) {
+	ctx := context.Background()
+	profileID := testPeers.GetTestPeerInfo(0).EncodedPeerID
+
+	build := func(version int) *Dscache {
+		builder := NewBuilder()
+		builder.AddUser("test_user", profileID)
+		builder.AddDsVersionInfo(dsref.VersionInfo{
+			InitID:    "init_id",
+			ProfileID: profileID,
+			Name:      "ds",
+			Path:      fmt.Sprintf("/ipfs/QmVersion%d", version),
+			MetaTitle: fmt.Sprintf("version %d", version),
+		})
+		return builder.Build()
+	}
+
+	const versions = 50
+	cache := &Dscache{}
+	if err := cache.Assign(build(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				vi, err := cache.LookupByName(dsref.Ref{Username: "test_user", Name: "ds"})
+				if err != nil {
+					errs <- err
+					return
+				}
+				// path & title are written together, a torn read would mismatch them
+				var pathV, titleV int
+				fmt.Sscanf(vi.Path, "/ipfs/QmVersion%d", &pathV)
+				fmt.Sscanf(vi.MetaTitle, "version %d", &titleV)
+				if vi.InitID != "init_id" || pathV != titleV {
+					errs <- fmt.Errorf("inconsistent read: %#v", vi)
+					return
+				}
+			}
+		}()
+	}
+
+	for v := 1; v <= versions; v++ {
+		if err := cache.Assign(build(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	ref := dsref.Ref{Username: "test_user", Name: "ds"}
+	if _, err := cache.ResolveRef(ctx, &ref); err != nil {
+		t.Fatal(err)
+	}
+	if expect := fmt.Sprintf("/ipfs/QmVersion%d", versions); ref.Path != expect {
+		t.Errorf("expected rebuilt data to be visible. want path: %q, got: %q", expect, ref.Path)
+	}
+}
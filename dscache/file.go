@@ -0,0 +1,91 @@
+package dscache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/qri-io/qri/dscache/dscachefb"
+)
+
+// ErrCorruptCache is returned when a dscache file can't be read
+var ErrCorruptCache = fmt.Errorf("dscache: corrupt cache file")
+
+// dscache files are a header followed by a flatbuffer. The header is:
+//
+//	magic    4 bytes  "qdsc"
+//	version  1 byte   fileVersion
+//	checksum 4 bytes  little-endian crc32 (IEEE) of the flatbuffer
+//
+// Files written before the header was introduced are bare flatbuffers, and
+// are still read. Files with an unknown version are treated as corrupt, and
+// rebuilt. Changes to the header or flatbuffer layout must bump fileVersion
+var fileMagic = []byte("qdsc")
+
+// fileVersion is the version of the dscache file format this package writes
+const fileVersion byte = 1
+
+// fileHeaderLen is the length of the magic string, version & checksum
+const fileHeaderLen = 9
+
+// encodeFile prefixes a flatbuffer with the dscache file header
+func encodeFile(buffer []byte) []byte {
+	data := make([]byte, fileHeaderLen+len(buffer))
+	copy(data, fileMagic)
+	data[len(fileMagic)] = fileVersion
+	binary.LittleEndian.PutUint32(data[len(fileMagic)+1:], crc32.ChecksumIEEE(buffer))
+	copy(data[fileHeaderLen:], buffer)
+	return data
+}
+
+// decodeFile checks the header of a dscache file, returning the flatbuffer it
+// contains. Files without a header are checked by reading the flatbuffer
+func decodeFile(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, fileMagic) {
+		return data, validateBuffer(data)
+	}
+	if len(data) < fileHeaderLen {
+		return nil, fmt.Errorf("%w: truncated header", ErrCorruptCache)
+	}
+	if v := data[len(fileMagic)]; v != fileVersion {
+		return nil, fmt.Errorf("%w: unsupported file version %d", ErrCorruptCache, v)
+	}
+	buffer := data[fileHeaderLen:]
+	if sum := binary.LittleEndian.Uint32(data[len(fileMagic)+1:]); sum != crc32.ChecksumIEEE(buffer) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptCache)
+	}
+	return buffer, nil
+}
+
+// validateBuffer reads every entry of a dscache flatbuffer. Flatbuffers
+// aren't verified when they're loaded, reading a corrupt buffer panics
+func validateBuffer(buffer []byte) (err error) {
+	if len(buffer) < flatbuffers.SizeUOffsetT {
+		return fmt.Errorf("%w: too short", ErrCorruptCache)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrCorruptCache, r)
+		}
+	}()
+
+	root := dscachefb.GetRootAsDscache(buffer, 0)
+	user := dscachefb.UserAssoc{}
+	for i := 0; i < root.UsersLength(); i++ {
+		root.Users(&user, i)
+		user.Username()
+		user.ProfileID()
+	}
+	ref := dscachefb.RefEntryInfo{}
+	for i := 0; i < root.RefsLength(); i++ {
+		root.Refs(&ref, i)
+		ref.InitID()
+		ref.ProfileID()
+		ref.PrettyName()
+		ref.HeadRef()
+		ref.FsiPath()
+	}
+	return nil
+}
@@ -5,11 +5,11 @@ import (
 	dscachefb "github.com/qri-io/qri/dscache/dscachefb"
 )
 
-func (d *Dscache) copyUserAssociationList(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
-	userList := make([]flatbuffers.UOffsetT, 0, d.Root.UsersLength())
-	for i := 0; i < d.Root.UsersLength(); i++ {
+func (d *Dscache) copyUserAssociationList(root *dscachefb.Dscache, builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	userList := make([]flatbuffers.UOffsetT, 0, root.UsersLength())
+	for i := 0; i < root.UsersLength(); i++ {
 		up := dscachefb.UserAssoc{}
-		d.Root.Users(&up, i)
+		root.Users(&up, i)
 		d.copyUserAssoc(builder, &up)
 		user := dscachefb.UserAssocEnd(builder)
 		userList = append(userList, user)
@@ -25,15 +25,16 @@ func (d *Dscache) copyUserAssociationList(builder *flatbuffers.Builder) flatbuff
 // For each entry in the dscache, copy it to the builder, unless it matches according to our
 // findMatchFunc, in which case, replace it by calling replaceRefFunc.
 func (d *Dscache) copyReferenceListWithReplacement(
+	root *dscachefb.Dscache,
 	builder *flatbuffers.Builder,
 	findMatchFunc func(*dscachefb.RefEntryInfo) bool,
 	replaceRefFunc func(func(*flatbuffers.Builder))) flatbuffers.UOffsetT {
 
 	// Construct refs, with all pertinent information for each dataset ref
-	refList := make([]flatbuffers.UOffsetT, 0, d.Root.RefsLength())
-	for i := 0; i < d.Root.RefsLength(); i++ {
+	refList := make([]flatbuffers.UOffsetT, 0, root.RefsLength())
+	for i := 0; i < root.RefsLength(); i++ {
 		r := dscachefb.RefEntryInfo{}
-		d.Root.Refs(&r, i)
+		root.Refs(&r, i)
 		// Check if this entry is the one that we want to modify.
 		if findMatchFunc(&r) {
 			// This is due to the flatbuffers API being a bit awkward to use.
@@ -0,0 +1,64 @@
+package dsref
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempt is the outcome of one pass a resolver makes at resolving a
+// reference. Resolvers that fall back to other strategies when a pass fails
+// make several attempts within a single ResolveRef call
+type Attempt struct {
+	// Stage names the strategy used, like "connected peers"
+	Stage string
+	// Requests is the number of requests the attempt sent, if any
+	Requests int
+	Found    bool
+	Err      error
+	Duration time.Duration
+}
+
+// Attempts collects the attempts made during reference resolution. Attach
+// Attempts to a context with WithAttempts to learn how many attempts a
+// resolution took, and which strategy resolved it
+type Attempts struct {
+	lk       sync.Mutex
+	attempts []Attempt
+}
+
+// Record adds an attempt. Record is nil-callable
+func (a *Attempts) Record(at Attempt) {
+	if a == nil {
+		return
+	}
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	a.attempts = append(a.attempts, at)
+}
+
+// List returns recorded attempts in the order they were made
+func (a *Attempts) List() []Attempt {
+	if a == nil {
+		return nil
+	}
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	res := make([]Attempt, len(a.attempts))
+	copy(res, a.attempts)
+	return res
+}
+
+type attemptsCtxKey struct{}
+
+// WithAttempts attaches an attempt collector to a context
+func WithAttempts(ctx context.Context, a *Attempts) context.Context {
+	return context.WithValue(ctx, attemptsCtxKey{}, a)
+}
+
+// AttemptsFromContext returns the attempt collector attached to a context, or
+// nil if the context has none
+func AttemptsFromContext(ctx context.Context) *Attempts {
+	a, _ := ctx.Value(attemptsCtxKey{}).(*Attempts)
+	return a
+}
@@ -0,0 +1,28 @@
+package dsref
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAttempts(t *testing.T) {
+	var nilAttempts *Attempts
+	nilAttempts.Record(Attempt{Stage: "a"})
+	if got := nilAttempts.List(); got != nil {
+		t.Errorf("expected nil attempts to list nothing, got: %v", got)
+	}
+
+	if AttemptsFromContext(context.Background()) != nil {
+		t.Errorf("expected context without attempts to return nil")
+	}
+
+	a := &Attempts{}
+	ctx := WithAttempts(context.Background(), a)
+	AttemptsFromContext(ctx).Record(Attempt{Stage: "a", Err: ErrRefNotFound})
+	AttemptsFromContext(ctx).Record(Attempt{Stage: "b", Found: true})
+
+	got := a.List()
+	if len(got) != 2 || got[0].Stage != "a" || got[1].Stage != "b" || !got[1].Found {
+		t.Errorf("expected attempts to be listed in order recorded, got: %v", got)
+	}
+}
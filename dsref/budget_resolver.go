@@ -0,0 +1,62 @@
+package dsref
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExceeded is returned by a BudgetedResolver once its time budget is
+// spent
+var ErrBudgetExceeded = errors.New("reference resolution time budget exceeded")
+
+// BudgetedResolver wraps a resolver, sharing a single time budget across all
+// resolutions. Per-call timeouts bound each resolution, but can add up to far
+// more than a caller is willing to wait when resolving many references.
+// Create a BudgetedResolver for a composite operation like handling a request,
+// and resolve with it for the lifetime of that operation. Once the budget is
+// spent, resolution fails immediately with ErrBudgetExceeded
+type BudgetedResolver struct {
+	resolver Resolver
+	deadline time.Time
+}
+
+// assert at compile time that BudgetedResolver is a Resolver
+var _ Resolver = (*BudgetedResolver)(nil)
+
+// NewBudgetedResolver wraps a resolver with a time budget that starts counting
+// down immediately
+func NewBudgetedResolver(r Resolver, budget time.Duration) *BudgetedResolver {
+	return &BudgetedResolver{
+		resolver: r,
+		deadline: time.Now().Add(budget),
+	}
+}
+
+// Remaining is the unspent time budget
+func (br *BudgetedResolver) Remaining() time.Duration {
+	if rem := time.Until(br.deadline); rem > 0 {
+		return rem
+	}
+	return 0
+}
+
+// ResolveRef resolves a reference with the wrapped resolver, cancelling
+// resolution when the budget runs out
+func (br *BudgetedResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	if br == nil || br.resolver == nil {
+		return "", ErrRefNotFound
+	}
+	if br.Remaining() == 0 {
+		return "", ErrBudgetExceeded
+	}
+
+	budgetCtx, cancel := context.WithDeadline(ctx, br.deadline)
+	defer cancel()
+	source, err := br.resolver.ResolveRef(budgetCtx, ref)
+	if err != nil && ctx.Err() == nil && budgetCtx.Err() != nil {
+		return "", fmt.Errorf("%w: %s", ErrBudgetExceeded, err)
+	}
+	return source, err
+}
@@ -0,0 +1,82 @@
+package dsref
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// delayResolver takes a fixed amount of time to resolve, giving up if the
+// context is done first
+type delayResolver struct {
+	Resolver
+	delay time.Duration
+	calls int
+}
+
+func (dr *delayResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	dr.calls++
+	select {
+	case <-time.After(dr.delay):
+		return dr.Resolver.ResolveRef(ctx, ref)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestBudgetedResolver(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := (*BudgetedResolver)(nil).ResolveRef(ctx, nil); err != ErrRefNotFound {
+		t.Errorf("ResolveRef must be nil-callable. expected: %q, got %v", ErrRefNotFound, err)
+	}
+
+	refs := map[string]Ref{
+		"a/b": {InitID: "init_id", Username: "a", Name: "b", Path: "/ipfs/QmHead"},
+	}
+	slow := &delayResolver{Resolver: StaticResolver(refs), delay: time.Millisecond * 20}
+	br := NewBudgetedResolver(slow, time.Millisecond*100)
+
+	// resolve until the budget runs out. with each call taking a fifth of the
+	// budget, calls must start failing well before ten
+	var (
+		successes int
+		err       error
+	)
+	for i := 0; i < 10 && err == nil; i++ {
+		if _, err = br.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); err == nil {
+			successes++
+		}
+	}
+	if successes == 0 {
+		t.Errorf("expected resolution to succeed within the budget")
+	}
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got: %v", err)
+	}
+	if br.Remaining() != 0 {
+		t.Errorf("expected no remaining budget, got: %s", br.Remaining())
+	}
+
+	// once spent, calls fail fast without consulting the wrapped resolver
+	calls := slow.calls
+	start := time.Now()
+	if _, err := br.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*10 {
+		t.Errorf("expected exhausted budget to fail fast, took: %s", elapsed)
+	}
+	if slow.calls != calls {
+		t.Errorf("expected exhausted budget not to call the wrapped resolver")
+	}
+
+	// a cancelled caller context isn't reported as an exhausted budget
+	br = NewBudgetedResolver(slow, time.Minute)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := br.ResolveRef(cancelled, &Ref{Username: "a", Name: "b"}); errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected caller cancellation not to be a budget error")
+	}
+}
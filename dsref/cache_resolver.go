@@ -0,0 +1,314 @@
+package dsref
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is the default length of time a CacheResolver retains a
+	// successful resolution
+	DefaultCacheTTL = time.Minute * 5
+	// DefaultNegativeCacheTTL is the default length of time a CacheResolver
+	// retains a failed resolution. Negative entries are kept for a much shorter
+	// time than successes, so references that appear are picked up quickly
+	DefaultNegativeCacheTTL = time.Second * 15
+	// DefaultCacheMaxEntries is the default number of references a
+	// CacheResolver holds
+	DefaultCacheMaxEntries = 4096
+)
+
+// CacheResolverOptions configures a CacheResolver
+type CacheResolverOptions struct {
+	// TTL is the length of time to cache successful resolutions
+	TTL time.Duration
+	// NegativeTTL is the length of time to cache ErrRefNotFound results
+	NegativeTTL time.Duration
+	// MaxEntries bounds the number of cached references, and separately the
+	// number of cached dataset heads. When full, expired entries are dropped,
+	// then the least recently used. Values less than 1 don't bound the cache
+	MaxEntries int
+}
+
+// CacheResolver wraps a resolver, caching both successful resolutions and
+// ErrRefNotFound results. Caching negative results avoids repeated expensive
+// lookups (like network calls) for references that don't exist. Errors other
+// than ErrRefNotFound are never cached
+//
+// References without a path (or with a version alias path like "latest")
+// resolve relative to the head of a dataset, which moves as new versions are
+// written. Heads are cached separately, keyed by InitID, and dropped with
+// InvalidateHead when a dataset's head changes
+type CacheResolver struct {
+	resolver    Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	// now returns the current time, overridden in tests
+	now func() time.Time
+
+	lk      sync.Mutex
+	entries map[string]cacheEntry
+	// heads maps InitID to the resolved head of a dataset
+	heads map[string]cacheEntry
+	// hits & misses count lookups answered with & without the cache
+	hits   int
+	misses int
+}
+
+// CacheStats summarizes how effective a CacheResolver has been
+type CacheStats struct {
+	// Hits is the number of resolutions answered from the cache, including
+	// cached ErrRefNotFound results
+	Hits int
+	// Misses is the number of resolutions passed to the wrapped resolver
+	Misses int
+}
+
+// HitRate is the fraction of resolutions answered from the cache, zero if
+// the cache hasn't been used
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type cacheEntry struct {
+	ref     Ref
+	source  string
+	found   bool
+	stored  time.Time
+	expires time.Time
+	// used is the last time the entry answered a lookup, for eviction
+	used time.Time
+	// head entries resolve the latest version of a dataset, reading the
+	// path from the heads cache
+	head bool
+	// relative entries resolve a version alias like "HEAD~1", and are
+	// dropped when the head of the dataset changes
+	relative bool
+}
+
+// assert at compile time that CacheResolver is a Resolver
+var _ Resolver = (*CacheResolver)(nil)
+
+// Freshness describes whether a resolution was answered from a cache or live
+// by the wrapped resolver
+type Freshness struct {
+	// Cached is true when the resolution came from the cache
+	Cached bool
+	// Age is the time since a cached resolution was made, zero for live
+	// resolutions
+	Age time.Duration
+}
+
+// NewCacheResolver wraps a resolver with a cache
+func NewCacheResolver(r Resolver, opts ...func(o *CacheResolverOptions)) *CacheResolver {
+	o := &CacheResolverOptions{
+		TTL:         DefaultCacheTTL,
+		NegativeTTL: DefaultNegativeCacheTTL,
+		MaxEntries:  DefaultCacheMaxEntries,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &CacheResolver{
+		resolver:    r,
+		ttl:         o.TTL,
+		negativeTTL: o.NegativeTTL,
+		maxEntries:  o.MaxEntries,
+		now:         time.Now,
+		entries:     map[string]cacheEntry{},
+		heads:       map[string]cacheEntry{},
+	}
+}
+
+// ResolveRef resolves a reference from the cache if a fresh entry exists,
+// otherwise resolving with the wrapped resolver and caching the result
+func (c *CacheResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	source, _, err := c.ResolveRefFreshness(ctx, ref)
+	return source, err
+}
+
+// ResolveRefFreshness resolves a reference, also reporting if the result came
+// from the cache and how old it is. Callers that care about staleness can use
+// freshness to decide whether to trust a resolution. Cached ErrRefNotFound
+// results report freshness as well
+func (c *CacheResolver) ResolveRefFreshness(ctx context.Context, ref *Ref) (string, Freshness, error) {
+	if c == nil || ref == nil {
+		return "", Freshness{}, ErrRefNotFound
+	}
+
+	key := cacheKey(*ref)
+	c.lk.Lock()
+	now := c.now()
+	ent, ok := c.entries[key]
+	if ok && now.After(ent.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if ok {
+		ent.used = now
+		c.entries[key] = ent
+	}
+	if ok && ent.head {
+		initID := ent.ref.InitID
+		ent, ok = c.heads[initID]
+		if ok && now.After(ent.expires) {
+			delete(c.heads, initID)
+			ok = false
+		}
+		if ok {
+			ent.used = now
+			c.heads[initID] = ent
+		}
+	}
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	c.lk.Unlock()
+
+	if ok {
+		fresh := Freshness{Cached: true, Age: c.now().Sub(ent.stored)}
+		if !ent.found {
+			return "", fresh, ErrRefNotFound
+		}
+		*ref = ent.ref.Copy()
+		return ent.source, fresh, nil
+	}
+
+	if c.resolver == nil {
+		return "", Freshness{}, ErrRefNotFound
+	}
+
+	resolved := ref.Copy()
+	source, err := c.resolver.ResolveRef(ctx, &resolved)
+	if errors.Is(err, ErrRefNotFound) {
+		c.lk.Lock()
+		now := c.now()
+		c.makeRoom(c.entries, key)
+		c.entries[key] = cacheEntry{stored: now, expires: now.Add(c.negativeTTL), used: now}
+		c.lk.Unlock()
+		return "", Freshness{}, err
+	} else if err != nil {
+		return "", Freshness{}, err
+	}
+
+	c.lk.Lock()
+	now = c.now()
+	ent = cacheEntry{
+		ref:     resolved.Copy(),
+		source:  source,
+		found:   true,
+		stored:  now,
+		expires: now.Add(c.ttl),
+		used:    now,
+	}
+	if gen, relative := headOffset(ref.Path); relative && resolved.InitID != "" {
+		if gen == 0 {
+			c.makeRoom(c.heads, resolved.InitID)
+			c.heads[resolved.InitID] = ent
+			ent.head = true
+		} else {
+			ent.relative = true
+		}
+	}
+	c.makeRoom(c.entries, key)
+	c.entries[key] = ent
+	// a success invalidates any negative entry for the same name
+	aliasKey := cacheKey(Ref{Username: resolved.Username, Name: resolved.Name, Type: resolved.Type})
+	if alias, ok := c.entries[aliasKey]; ok && !alias.found {
+		delete(c.entries, aliasKey)
+	}
+	c.lk.Unlock()
+
+	*ref = resolved
+	return source, Freshness{}, nil
+}
+
+// Stats returns cache hit & miss counts
+func (c *CacheResolver) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses}
+}
+
+// Invalidate drops any cached entry for a reference
+func (c *CacheResolver) Invalidate(ref Ref) {
+	if c == nil {
+		return
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	delete(c.entries, cacheKey(ref))
+}
+
+// InvalidateHead drops the cached head of a dataset, along with any cached
+// names that resolve to that dataset's head. Call InvalidateHead whenever the
+// head of a dataset changes
+func (c *CacheResolver) InvalidateHead(initID string) {
+	if c == nil {
+		return
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	delete(c.heads, initID)
+	for key, ent := range c.entries {
+		if (ent.head || ent.relative) && ent.ref.InitID == initID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// makeRoom frees space in a full cache map for a new key, dropping expired
+// entries, then the least recently used entry if the map is still full.
+// Callers must hold the lock
+func (c *CacheResolver) makeRoom(m map[string]cacheEntry, key string) {
+	if _, ok := m[key]; ok || c.maxEntries < 1 || len(m) < c.maxEntries {
+		return
+	}
+	now := c.now()
+	for k, ent := range m {
+		if now.After(ent.expires) {
+			delete(m, k)
+		}
+	}
+	if len(m) < c.maxEntries {
+		return
+	}
+
+	var (
+		lruKey string
+		lru    cacheEntry
+		found  bool
+	)
+	for k, ent := range m {
+		if !found || ent.used.Before(lru.used) {
+			lruKey, lru, found = k, ent, true
+		}
+	}
+	delete(m, lruKey)
+}
+
+func cacheKey(ref Ref) string {
+	return ref.Canonical()
+}
+
+// headOffset reports if a path resolves relative to the head of a dataset,
+// and how many versions behind the head it refers to
+func headOffset(path string) (gen int, relative bool) {
+	if path == "" {
+		return 0, true
+	}
+	gen, ok, err := ParseVersionAlias(path)
+	return gen, ok && err == nil
+}
@@ -0,0 +1,196 @@
+package dsref
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingResolver counts calls to an underlying resolver
+type countingResolver struct {
+	Resolver
+	calls int
+}
+
+func (cr *countingResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	cr.calls++
+	return cr.Resolver.ResolveRef(ctx, ref)
+}
+
+func TestCacheResolver(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := (*CacheResolver)(nil).ResolveRef(ctx, nil); err != ErrRefNotFound {
+		t.Errorf("ResolveRef must be nil-callable. expected: %q, got %v", ErrRefNotFound, err)
+	}
+
+	refs := map[string]Ref{}
+	counter := &countingResolver{Resolver: StaticResolver(refs)}
+	c := NewCacheResolver(counter, func(o *CacheResolverOptions) {
+		o.TTL = time.Minute
+		o.NegativeTTL = time.Second
+	})
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// cache a not-found
+	for i := 0; i < 2; i++ {
+		if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); err != ErrRefNotFound {
+			t.Fatalf("expected ErrRefNotFound, got: %v", err)
+		}
+	}
+	if counter.calls != 1 {
+		t.Errorf("expected negative result to be cached. resolver calls: %d", counter.calls)
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.HitRate() != 0.5 {
+		t.Errorf("expected one hit & one miss, got: %+v", stats)
+	}
+
+	// make the ref available, the negative entry still applies
+	expect := Ref{InitID: "init_id", Username: "a", ProfileID: "QmProfileID", Name: "b", Path: "/ipfs/QmHead"}
+	refs["a/b"] = expect
+	if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); err != ErrRefNotFound {
+		t.Errorf("expected negative entry to be used before negative TTL, got: %v", err)
+	}
+
+	// after the negative TTL, resolution succeeds
+	now = now.Add(time.Second * 2)
+	got := Ref{Username: "a", Name: "b"}
+	if _, err := c.ResolveRef(ctx, &got); err != nil {
+		t.Fatalf("expected resolution to succeed after negative TTL, got: %s", err)
+	}
+	if !expect.Equals(got) {
+		t.Errorf("result mismatch. expected: %s, got: %s", expect, got)
+	}
+
+	// successes are cached for the longer TTL
+	calls := counter.calls
+	now = now.Add(time.Second * 30)
+	got = Ref{Username: "a", Name: "b"}
+	if _, err := c.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if counter.calls != calls {
+		t.Errorf("expected cached success to skip the resolver")
+	}
+
+	// explicit invalidation drops a negative entry immediately
+	if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: "c"}); err != ErrRefNotFound {
+		t.Fatalf("expected ErrRefNotFound, got: %v", err)
+	}
+	refs["a/c"] = Ref{InitID: "init_id_c", Username: "a", Name: "c"}
+	c.Invalidate(Ref{Username: "a", Name: "c"})
+	if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: "c"}); err != nil {
+		t.Errorf("expected resolution to succeed after invalidation, got: %s", err)
+	}
+
+	// a success resolving a more specific reference clears the negative entry
+	// for the human-friendly name
+	if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: "d"}); err != ErrRefNotFound {
+		t.Fatalf("expected ErrRefNotFound, got: %v", err)
+	}
+	refs["a/d"] = Ref{InitID: "init_id_d", Username: "a", Name: "d", Path: "/ipfs/QmD"}
+	if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: "d", Path: "/ipfs/QmD"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: "d"}); err != nil {
+		t.Errorf("expected success to invalidate negative entry, got: %v", err)
+	}
+}
+
+func TestCacheResolverFreshness(t *testing.T) {
+	ctx := context.Background()
+
+	if _, _, err := (*CacheResolver)(nil).ResolveRefFreshness(ctx, nil); err != ErrRefNotFound {
+		t.Errorf("ResolveRefFreshness must be nil-callable. expected: %q, got %v", ErrRefNotFound, err)
+	}
+
+	refs := map[string]Ref{
+		"a/b": {InitID: "init_id", Username: "a", ProfileID: "QmProfileID", Name: "b", Path: "/ipfs/QmHead"},
+	}
+	c := NewCacheResolver(StaticResolver(refs))
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// the first resolution is live
+	_, fresh, err := c.ResolveRefFreshness(ctx, &Ref{Username: "a", Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Cached || fresh.Age != 0 {
+		t.Errorf("expected first resolution to be live, got: %+v", fresh)
+	}
+
+	// the second is served from the cache, reporting its age
+	now = now.Add(time.Second * 30)
+	got := Ref{Username: "a", Name: "b"}
+	_, fresh, err = c.ResolveRefFreshness(ctx, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fresh.Cached || fresh.Age != time.Second*30 {
+		t.Errorf("expected cached resolution 30s old, got: %+v", fresh)
+	}
+	if got.Path != "/ipfs/QmHead" {
+		t.Errorf("expected cached resolution to populate ref, got path: %q", got.Path)
+	}
+
+	// cached not-found results report freshness too
+	c.ResolveRefFreshness(ctx, &Ref{Username: "a", Name: "missing"})
+	now = now.Add(time.Second)
+	if _, fresh, err = c.ResolveRefFreshness(ctx, &Ref{Username: "a", Name: "missing"}); err != ErrRefNotFound {
+		t.Fatalf("expected ErrRefNotFound, got: %v", err)
+	}
+	if !fresh.Cached || fresh.Age != time.Second {
+		t.Errorf("expected cached not-found 1s old, got: %+v", fresh)
+	}
+}
+
+func TestCacheResolverMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	refs := map[string]Ref{
+		"a/one":   {InitID: "init_one", Username: "a", ProfileID: "QmProfileID", Name: "one", Path: "/ipfs/QmOne"},
+		"a/two":   {InitID: "init_two", Username: "a", ProfileID: "QmProfileID", Name: "two", Path: "/ipfs/QmTwo"},
+		"a/three": {InitID: "init_three", Username: "a", ProfileID: "QmProfileID", Name: "three", Path: "/ipfs/QmThree"},
+	}
+	counter := &countingResolver{Resolver: StaticResolver(refs)}
+	c := NewCacheResolver(counter, func(o *CacheResolverOptions) {
+		o.TTL = time.Minute
+		o.MaxEntries = 2
+	})
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	resolve := func(name string) {
+		now = now.Add(time.Second)
+		if _, err := c.ResolveRef(ctx, &Ref{Username: "a", Name: name}); err != nil {
+			t.Fatalf("resolving %q: %s", name, err)
+		}
+	}
+
+	resolve("one")
+	resolve("two")
+	// using "one" makes "two" the least recently used entry
+	resolve("one")
+	resolve("three")
+	if len(c.entries) != 2 || len(c.heads) != 2 {
+		t.Errorf("expected cache to hold 2 entries & heads, got: %d entries, %d heads", len(c.entries), len(c.heads))
+	}
+
+	calls := counter.calls
+	resolve("one")
+	resolve("three")
+	if counter.calls != calls {
+		t.Errorf("expected recently used entries to stay cached")
+	}
+	resolve("two")
+	if counter.calls != calls+1 {
+		t.Errorf("expected least recently used entry to be evicted")
+	}
+
+	// expired entries are dropped before live ones
+	now = now.Add(time.Minute * 2)
+	resolve("one")
+	if _, ok := c.entries[cacheKey(Ref{Username: "a", Name: "three"})]; ok {
+		t.Errorf("expected expired entries to be swept when the cache is full")
+	}
+}
@@ -0,0 +1,177 @@
+package dsref
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileIndexResolver resolves references against a static index file that maps
+// dataset names & init IDs to paths. It has no network or logbook
+// dependencies, making it suitable for offline & reproducible environments
+// like CI. The index is reloaded whenever the file changes
+//
+// Index files are either JSON or CSV, chosen by file extension. JSON indexes
+// are an array of reference objects:
+//
+//	[{ "initID": "...", "username": "...", "profileID": "...", "name": "...", "path": "..." }]
+//
+// CSV indexes must start with a header row naming the same fields. Columns
+// can appear in any order, and unknown columns are ignored
+type FileIndexResolver struct {
+	path string
+
+	lk      sync.Mutex
+	modTime time.Time
+	size    int64
+	byAlias map[string]Ref
+	byID    map[string]Ref
+}
+
+// assert at compile time that FileIndexResolver is a Resolver
+var _ Resolver = (*FileIndexResolver)(nil)
+
+// NewFileIndexResolver creates a resolver backed by the index file at path,
+// returning an error if the index can't be loaded
+func NewFileIndexResolver(path string) (*FileIndexResolver, error) {
+	r := &FileIndexResolver{path: path}
+	if err := r.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ResolveRef finds the identifier & head path for a dataset reference,
+// reloading the index first if the file has changed
+func (r *FileIndexResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	if r == nil || ref == nil {
+		return "", ErrRefNotFound
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if err := r.reloadIfChanged(); err != nil {
+		return "", err
+	}
+
+	var (
+		found Ref
+		ok    bool
+	)
+	if ref.Username != "" || ref.Name != "" {
+		found, ok = r.byAlias[ref.Alias()]
+	} else if ref.InitID != "" {
+		found, ok = r.byID[ref.InitID]
+	}
+	if !ok {
+		return "", ErrRefNotFound
+	}
+
+	ref.InitID = found.InitID
+	ref.Username = found.Username
+	ref.ProfileID = found.ProfileID
+	ref.Name = found.Name
+	if ref.Path == "" {
+		ref.Path = found.Path
+	}
+	return "", nil
+}
+
+// reloadIfChanged reads the index file if its modification time or size
+// differs from the last load. callers must hold the lock, or have exclusive
+// access to the resolver
+func (r *FileIndexResolver) reloadIfChanged() error {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("reading index file: %w", err)
+	}
+	if r.byAlias != nil && fi.ModTime().Equal(r.modTime) && fi.Size() == r.size {
+		return nil
+	}
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("reading index file: %w", err)
+	}
+	defer f.Close()
+
+	var refs []Ref
+	switch strings.ToLower(filepath.Ext(r.path)) {
+	case ".json":
+		if err := json.NewDecoder(f).Decode(&refs); err != nil {
+			return fmt.Errorf("parsing JSON index file %q: %w", r.path, err)
+		}
+	case ".csv":
+		if refs, err = readCSVIndex(f); err != nil {
+			return fmt.Errorf("parsing CSV index file %q: %w", r.path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported index file extension %q, must be .json or .csv", filepath.Ext(r.path))
+	}
+
+	byAlias := make(map[string]Ref, len(refs))
+	byID := make(map[string]Ref, len(refs))
+	for _, ref := range refs {
+		if ref.Username != "" && ref.Name != "" {
+			byAlias[ref.Alias()] = ref
+		}
+		if ref.InitID != "" {
+			byID[ref.InitID] = ref
+		}
+	}
+
+	r.byAlias = byAlias
+	r.byID = byID
+	r.modTime = fi.ModTime()
+	r.size = fi.Size()
+	return nil
+}
+
+func readCSVIndex(rdr io.Reader) ([]Ref, error) {
+	cr := csv.NewReader(rdr)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.TrimSpace(name)] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var refs []Ref
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		refs = append(refs, Ref{
+			InitID:    field(row, "initID"),
+			Username:  field(row, "username"),
+			ProfileID: field(row, "profileID"),
+			Name:      field(row, "name"),
+			Path:      field(row, "path"),
+		})
+	}
+	return refs, nil
+}
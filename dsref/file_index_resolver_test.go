@@ -0,0 +1,107 @@
+package dsref_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/qri/dsref"
+	dsrefspec "github.com/qri-io/qri/dsref/spec"
+	"github.com/qri-io/qri/identity"
+	"github.com/qri-io/qri/logbook/oplog"
+)
+
+func TestFileIndexResolver(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "file_index_resolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := (*dsref.FileIndexResolver)(nil).ResolveRef(ctx, nil); err != dsref.ErrRefNotFound {
+		t.Errorf("ResolveRef must be nil-callable. expected: %q, got %v", dsref.ErrRefNotFound, err)
+	}
+	if _, err := dsref.NewFileIndexResolver(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("expected missing index file to error")
+	}
+
+	jsonPath := filepath.Join(dir, "index.json")
+	writeIndex := func(refs []dsref.Ref) {
+		data, err := json.Marshal(refs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(jsonPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeIndex([]dsref.Ref{})
+
+	r, err := dsref.NewFileIndexResolver(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var refs []dsref.Ref
+	dsrefspec.AssertResolverSpec(t, r, func(_ context.Context, ref dsref.Ref, author identity.Author, _ *oplog.Log) error {
+		pid, err := identity.KeyIDFromPub(author.AuthorPubKey())
+		if err != nil {
+			return err
+		}
+		ref.ProfileID = pid
+		refs = append(refs, ref)
+		writeIndex(refs)
+		return nil
+	})
+
+	csvPath := filepath.Join(dir, "index.csv")
+	csvData := "name,username,initID,path\nds,peer,init_id,/ipfs/QmFirst\n"
+	if err := ioutil.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err = dsref.NewFileIndexResolver(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := dsref.Ref{Username: "peer", Name: "ds"}
+	if _, err := r.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	expect := dsref.Ref{InitID: "init_id", Username: "peer", Name: "ds", Path: "/ipfs/QmFirst"}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	byID := dsref.Ref{InitID: "init_id"}
+	if _, err := r.ResolveRef(ctx, &byID); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect, byID); diff != "" {
+		t.Errorf("init ID result mismatch (-want +got):\n%s", diff)
+	}
+
+	// changing the file must be picked up on the next resolution
+	csvData = "name,username,initID,path\nds,peer,init_id,/ipfs/QmSecond\n"
+	if err := ioutil.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(csvPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	got = dsref.Ref{Username: "peer", Name: "ds"}
+	if _, err := r.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "/ipfs/QmSecond" {
+		t.Errorf("expected resolver to reload changed index. want path: %q, got: %q", "/ipfs/QmSecond", got.Path)
+	}
+}
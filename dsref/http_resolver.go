@@ -0,0 +1,142 @@
+package dsref
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultHTTPResolverTimeout is the default length of time an HTTPResolver
+	// waits for a single gateway request
+	DefaultHTTPResolverTimeout = time.Second * 10
+	// DefaultHTTPResolverRetries is the default number of times an
+	// HTTPResolver retries a failed gateway request
+	DefaultHTTPResolverRetries = 2
+	// DefaultHTTPResolverRetryDelay is the default wait between retries
+	DefaultHTTPResolverRetryDelay = time.Millisecond * 250
+)
+
+// HTTPResolverOptions configures an HTTPResolver
+type HTTPResolverOptions struct {
+	// Timeout bounds each request to the gateway
+	Timeout time.Duration
+	// Retries is the number of times to retry a request that fails with a
+	// network error or server error status
+	Retries int
+	// RetryDelay is the length of time to wait between retries
+	RetryDelay time.Duration
+	// Client performs requests, defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// HTTPResolver resolves references through an HTTP gateway, letting clients
+// that don't run a p2p node resolve over the network. Requests POST the JSON
+// encoded reference to the gateway URL, which responds with the completed
+// reference as JSON. Gateways respond with 404 Not Found for references they
+// can't resolve
+type HTTPResolver struct {
+	url        string
+	timeout    time.Duration
+	retries    int
+	retryDelay time.Duration
+	client     *http.Client
+}
+
+// assert at compile time that HTTPResolver is a Resolver
+var _ Resolver = (*HTTPResolver)(nil)
+
+// NewHTTPResolver creates a resolver backed by the gateway at gatewayURL
+func NewHTTPResolver(gatewayURL string, opts ...func(o *HTTPResolverOptions)) *HTTPResolver {
+	o := &HTTPResolverOptions{
+		Timeout:    DefaultHTTPResolverTimeout,
+		Retries:    DefaultHTTPResolverRetries,
+		RetryDelay: DefaultHTTPResolverRetryDelay,
+		Client:     http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &HTTPResolver{
+		url:        gatewayURL,
+		timeout:    o.Timeout,
+		retries:    o.Retries,
+		retryDelay: o.RetryDelay,
+		client:     o.Client,
+	}
+}
+
+// ResolveRef asks the gateway to resolve a reference, returning the gateway
+// URL as the source
+func (hr *HTTPResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	if hr == nil || ref == nil {
+		return "", ErrRefNotFound
+	}
+
+	body, err := json.Marshal(ref)
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		resolved, retry, err := hr.request(ctx, body)
+		if err == nil {
+			*ref = resolved
+			return hr.url, nil
+		}
+		if !retry || attempt >= hr.retries {
+			return "", err
+		}
+
+		select {
+		case <-time.After(hr.retryDelay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// request performs a single gateway request, reporting if a failed request
+// is worth retrying
+func (hr *HTTPResolver) request(ctx context.Context, body []byte) (ref Ref, retry bool, err error) {
+	reqCtx, cancel := context.WithTimeout(ctx, hr.timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, hr.url, bytes.NewReader(body))
+	if err != nil {
+		return ref, false, err
+	}
+	req = req.WithContext(reqCtx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := hr.client.Do(req)
+	if err != nil {
+		// errors caused by the caller's context aren't worth retrying
+		return ref, ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		if err := json.NewDecoder(res.Body).Decode(&ref); err != nil {
+			return ref, false, fmt.Errorf("decoding gateway response: %w", err)
+		}
+		return ref, false, nil
+	case res.StatusCode == http.StatusNotFound:
+		return ref, false, ErrRefNotFound
+	default:
+		msg, _ := ioutil.ReadAll(res.Body)
+		err := fmt.Errorf("resolving reference from gateway %s failed. status %d: %s", hr.url, res.StatusCode, strings.TrimSpace(string(msg)))
+		return ref, res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests, err
+	}
+}
@@ -0,0 +1,124 @@
+package dsref_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/qri/dsref"
+	dsrefspec "github.com/qri-io/qri/dsref/spec"
+	"github.com/qri-io/qri/identity"
+	"github.com/qri-io/qri/logbook/oplog"
+)
+
+// gatewayHandler serves reference resolution requests from a resolver
+func gatewayHandler(r dsref.Resolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ref := dsref.Ref{}
+		if err := json.NewDecoder(req.Body).Decode(&ref); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := r.ResolveRef(req.Context(), &ref); errors.Is(err, dsref.ErrRefNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(ref)
+	}
+}
+
+func TestHTTPResolver(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := (*dsref.HTTPResolver)(nil).ResolveRef(ctx, nil); err != dsref.ErrRefNotFound {
+		t.Errorf("ResolveRef must be nil-callable. expected: %q, got %v", dsref.ErrRefNotFound, err)
+	}
+
+	mem := dsref.NewMemResolver("gateway")
+	s := httptest.NewServer(gatewayHandler(mem))
+	defer s.Close()
+
+	r := dsref.NewHTTPResolver(s.URL)
+	dsrefspec.AssertResolverSpec(t, r, func(_ context.Context, ref dsref.Ref, author identity.Author, _ *oplog.Log) error {
+		pid, err := identity.KeyIDFromPub(author.AuthorPubKey())
+		if err != nil {
+			return err
+		}
+		mem.Put(dsref.VersionInfo{
+			InitID:    ref.InitID,
+			ProfileID: pid,
+			Username:  ref.Username,
+			Name:      ref.Name,
+			Path:      ref.Path,
+		})
+		return nil
+	})
+
+	complete := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmeXaMpLe"}
+	var calls int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			http.Error(w, "temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(complete)
+	}))
+	defer flaky.Close()
+
+	r = dsref.NewHTTPResolver(flaky.URL, func(o *dsref.HTTPResolverOptions) {
+		o.RetryDelay = time.Millisecond
+	})
+	got := dsref.Ref{Username: "peer", Name: "dataset"}
+	source, err := r.ResolveRef(ctx, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != flaky.URL {
+		t.Errorf("source mismatch. expected: %q, got: %q", flaky.URL, source)
+	}
+	if diff := cmp.Diff(complete, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+	if calls != 2 {
+		t.Errorf("expected one retry after a server error, got %d requests", calls)
+	}
+
+	// client errors aren't retried
+	calls = 0
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	r = dsref.NewHTTPResolver(bad.URL)
+	if _, err := r.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "dataset"}); err == nil {
+		t.Errorf("expected error from failed request")
+	}
+	if calls != 1 {
+		t.Errorf("expected client errors not to be retried, got %d requests", calls)
+	}
+
+	// requests that exceed the timeout fail
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	r = dsref.NewHTTPResolver(slow.URL, func(o *dsref.HTTPResolverOptions) {
+		o.Timeout = time.Millisecond * 20
+		o.Retries = 0
+	})
+	if _, err := r.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "dataset"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected request timeout to return context.DeadlineExceeded, got: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package dsref
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LocalFirstVerifyTimeout bounds the time a LocalFirstResolver spends checking
+// a remote resolver for a newer head after returning a local resolution
+const LocalFirstVerifyTimeout = time.Second * 30
+
+// OnNewerHeadFunc is called by a LocalFirstResolver when a remote resolver
+// reports a newer head for a dataset than the local resolver returned.
+// source is the remote source of the newer head
+type OnNewerHeadFunc func(local, remote Ref, source string)
+
+// DescendsFunc reports whether the remote head of a dataset descends from the
+// local head, meaning the local head is in the version history leading up to
+// the remote head. source is the remote source of the remote head, where the
+// remote version history can be fetched. Implementations should report false
+// when descent can't be established
+type DescendsFunc func(ctx context.Context, local, remote Ref, source string) (bool, error)
+
+// LocalFirstResolver composes a local & remote resolver into one that returns
+// local resolutions immediately, then checks the remote resolver for a newer
+// head in the background. onNewer is called when the remote head for the same
+// dataset differs from the local one, and descends reports the remote head
+// descends from the local head. A differing remote head can also be behind
+// the local head or diverged from it, neither of which fire onNewer.
+// References that don't resolve locally resolve with the remote resolver.
+// Only references to the head of a dataset are checked, references with an
+// explicit path are fixed to a version and can't go stale
+func LocalFirstResolver(local, remote Resolver, descends DescendsFunc, onNewer OnNewerHeadFunc) Resolver {
+	return localFirstResolver{local: local, remote: remote, descends: descends, onNewer: onNewer}
+}
+
+type localFirstResolver struct {
+	local    Resolver
+	remote   Resolver
+	descends DescendsFunc
+	onNewer  OnNewerHeadFunc
+}
+
+func (lf localFirstResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	if lf.local == nil {
+		return sequentialResolver{lf.remote}.ResolveRef(ctx, ref)
+	}
+
+	req := ref.Copy()
+	source, err := lf.local.ResolveRef(ctx, ref)
+	if errors.Is(err, ErrRefNotFound) {
+		return sequentialResolver{lf.remote}.ResolveRef(ctx, ref)
+	} else if err != nil {
+		return "", err
+	}
+
+	if lf.remote != nil && lf.descends != nil && lf.onNewer != nil && req.Path == "" {
+		go lf.verify(req, ref.Copy())
+	}
+	return source, nil
+}
+
+// verify checks the remote resolver for a head that descends from a local
+// resolution. verify outlives the call to ResolveRef, and doesn't use the
+// caller's context
+func (lf localFirstResolver) verify(req, local Ref) {
+	ctx, cancel := context.WithTimeout(context.Background(), LocalFirstVerifyTimeout)
+	defer cancel()
+
+	remote := req.Copy()
+	source, err := lf.remote.ResolveRef(ctx, &remote)
+	if err != nil {
+		return
+	}
+	if remote.InitID != local.InitID || remote.Path == local.Path {
+		return
+	}
+	if newer, err := lf.descends(ctx, local, remote, source); err == nil && newer {
+		lf.onNewer(local, remote, source)
+	}
+}
@@ -0,0 +1,145 @@
+package dsref
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// gatedResolver blocks resolution until released, signalling each call
+type gatedResolver struct {
+	Resolver
+	release chan struct{}
+	called  chan struct{}
+}
+
+func (gr *gatedResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	gr.called <- struct{}{}
+	select {
+	case <-gr.release:
+		return gr.Resolver.ResolveRef(ctx, ref)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// descendsFrom reports descent using a single linear history of head paths,
+// oldest first
+func descendsFrom(paths ...string) DescendsFunc {
+	index := func(path string) int {
+		for i, p := range paths {
+			if p == path {
+				return i
+			}
+		}
+		return -1
+	}
+	return func(_ context.Context, local, remote Ref, _ string) (bool, error) {
+		li, ri := index(local.Path), index(remote.Path)
+		return li >= 0 && ri > li, nil
+	}
+}
+
+type newerHead struct {
+	local, remote Ref
+	source        string
+}
+
+func TestLocalFirstResolver(t *testing.T) {
+	ctx := context.Background()
+
+	stale := Ref{InitID: "init_id", Username: "a", Name: "b", Path: "/ipfs/QmStale"}
+	latest := Ref{InitID: "init_id", Username: "a", Name: "b", Path: "/ipfs/QmLatest"}
+	local := StaticResolver(map[string]Ref{"a/b": stale})
+	remote := &gatedResolver{
+		Resolver: StaticResolver(map[string]Ref{"a/b": latest, "a/remote_only": {InitID: "init_id_2", Username: "a", Name: "remote_only", Path: "/ipfs/QmRemote"}}),
+		release:  make(chan struct{}),
+		called:   make(chan struct{}, 10),
+	}
+	newer := make(chan newerHead, 1)
+	history := descendsFrom("/ipfs/QmStale", "/ipfs/QmLatest")
+	r := LocalFirstResolver(local, remote, history, func(local, remote Ref, source string) {
+		newer <- newerHead{local, remote, source}
+	})
+
+	// the local version returns while the remote check is still blocked
+	got := Ref{Username: "a", Name: "b"}
+	if _, err := r.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !stale.Equals(got) {
+		t.Errorf("expected local resolution. expected: %s, got: %s", stale, got)
+	}
+	select {
+	case <-remote.called:
+	case <-time.After(time.Second):
+		t.Fatal("expected remote resolver to be checked for a newer head")
+	}
+	select {
+	case <-newer:
+		t.Fatal("callback fired before the remote resolver responded")
+	default:
+	}
+
+	close(remote.release)
+	select {
+	case res := <-newer:
+		if !stale.Equals(res.local) {
+			t.Errorf("callback local ref mismatch. expected: %s, got: %s", stale, res.local)
+		}
+		if !latest.Equals(res.remote) {
+			t.Errorf("callback remote ref mismatch. expected: %s, got: %s", latest, res.remote)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected callback to fire with the newer remote head")
+	}
+
+	// references with a path aren't checked
+	got = Ref{Username: "a", Name: "b", Path: "/ipfs/QmStale"}
+	if _, err := r.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// references that don't resolve locally resolve remotely
+	got = Ref{Username: "a", Name: "remote_only"}
+	if _, err := r.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "/ipfs/QmRemote" {
+		t.Errorf("expected remote resolution, got path: %q", got.Path)
+	}
+	<-remote.called
+	select {
+	case <-remote.called:
+		t.Error("expected only the remote-only reference to call the remote resolver")
+	default:
+	}
+
+	// an up to date local head doesn't fire the callback
+	r = LocalFirstResolver(StaticResolver(map[string]Ref{"a/b": latest}), remote, history, func(local, remote Ref, source string) {
+		newer <- newerHead{local, remote, source}
+	})
+	if _, err := r.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	<-remote.called
+	select {
+	case <-newer:
+		t.Error("expected no callback when the local head is up to date")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// a local head ahead of the remote head doesn't fire the callback
+	behind := StaticResolver(map[string]Ref{"a/b": stale})
+	r = LocalFirstResolver(StaticResolver(map[string]Ref{"a/b": latest}), behind, history, func(local, remote Ref, source string) {
+		newer <- newerHead{local, remote, source}
+	})
+	if _, err := r.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case res := <-newer:
+		t.Errorf("expected no callback when the local head is ahead, got remote: %s", res.remote)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
@@ -48,6 +48,10 @@ func (m *MemResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error)
 		return "", ErrRefNotFound
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	id := m.RefMap[ref.Alias()]
 	resolved, ok := m.IDMap[id]
 	if !ok {
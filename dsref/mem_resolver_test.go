@@ -18,7 +18,7 @@ func TestMemResolver(t *testing.T) {
 		t.Errorf("ResolveRef must be nil-callable. expected: %q, got %v", dsref.ErrRefNotFound, err)
 	}
 
-	dsrefspec.AssertResolverSpec(t, m, func(ref dsref.Ref, author identity.Author, log *oplog.Log) error {
+	dsrefspec.AssertResolverSpec(t, m, func(_ context.Context, ref dsref.Ref, author identity.Author, log *oplog.Log) error {
 		pid, err := identity.KeyIDFromPub(author.AuthorPubKey())
 		if err != nil {
 			return err
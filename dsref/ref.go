@@ -1,5 +1,11 @@
 package dsref
 
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
 // Ref is a reference to a dataset
 type Ref struct {
 	// InitID is the canonical identifer for a dataset history
@@ -12,6 +18,9 @@ type Ref struct {
 	Name string `json:"name,omitempty"`
 	// Content-addressed path for this dataset
 	Path string `json:"path,omitempty"`
+	// Type discriminates the kind of thing a reference refers to. The empty
+	// string refers to a dataset
+	Type string `json:"type,omitempty"`
 }
 
 // Alias returns the alias components of a Ref as a string
@@ -44,9 +53,120 @@ func (r Ref) String() (s string) {
 	return s
 }
 
+// Canonical returns a normalized string form of the reference that includes
+// every field, and round-trips losslessly through ParseCanonical. Two
+// references that are Equal after normalization have the same canonical form,
+// making it suitable as a map key. The format is:
+//
+//	[type:][username/name][@[profileID][/path]][#initID]
+//
+// Paths are normalized to start with a slash. Separator characters & "%" are
+// percent-encoded within fields, all other characters (including unicode) are
+// written as-is
+func (r Ref) Canonical() string {
+	var b strings.Builder
+	if r.Type != "" {
+		b.WriteString(escapeCanonical(r.Type, false) + ":")
+	}
+	if r.Username != "" || r.Name != "" {
+		b.WriteString(escapeCanonical(r.Username, false) + "/" + escapeCanonical(r.Name, false))
+	}
+	if r.ProfileID != "" || r.Path != "" {
+		b.WriteString("@" + escapeCanonical(r.ProfileID, false))
+		if r.Path != "" {
+			b.WriteString(escapeCanonical(normalizePath(r.Path), true))
+		}
+	}
+	if r.InitID != "" {
+		b.WriteString("#" + escapeCanonical(r.InitID, false))
+	}
+	return b.String()
+}
+
+// ParseCanonical parses a string written by Ref.Canonical
+func ParseCanonical(text string) (Ref, error) {
+	var (
+		r   Ref
+		err error
+	)
+	if i := strings.IndexByte(text, ':'); i >= 0 {
+		if r.Type, err = unescapeCanonical(text[:i]); err != nil {
+			return r, err
+		}
+		text = text[i+1:]
+	}
+	if i := strings.IndexByte(text, '#'); i >= 0 {
+		if r.InitID, err = unescapeCanonical(text[i+1:]); err != nil {
+			return r, err
+		}
+		text = text[:i]
+	}
+	if i := strings.IndexByte(text, '@'); i >= 0 {
+		version := text[i+1:]
+		if j := strings.IndexByte(version, '/'); j >= 0 {
+			if r.Path, err = unescapeCanonical(version[j:]); err != nil {
+				return r, err
+			}
+			version = version[:j]
+		}
+		if r.ProfileID, err = unescapeCanonical(version); err != nil {
+			return r, err
+		}
+		text = text[:i]
+	}
+	if text != "" {
+		i := strings.IndexByte(text, '/')
+		if i < 0 {
+			return r, fmt.Errorf("canonical reference %q: username and name must be separated by '/'", text)
+		}
+		if r.Username, err = unescapeCanonical(text[:i]); err != nil {
+			return r, err
+		}
+		if r.Name, err = unescapeCanonical(text[i+1:]); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// normalizePath ensures a non-empty path starts with a single slash
+func normalizePath(p string) string {
+	if p == "" {
+		return p
+	}
+	return "/" + strings.TrimLeft(p, "/")
+}
+
+func escapeCanonical(s string, isPath bool) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '/':
+			if isPath {
+				b.WriteRune(c)
+				continue
+			}
+			fallthrough
+		case '%', ':', '@', '#':
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func unescapeCanonical(s string) (string, error) {
+	u, err := url.PathUnescape(s)
+	if err != nil {
+		return "", fmt.Errorf("canonical reference: %w", err)
+	}
+	return u, nil
+}
+
 // IsEmpty returns whether the reference is empty
 func (r Ref) IsEmpty() bool {
-	return r.InitID == "" && r.Username == "" && r.ProfileID == "" && r.Name == "" && r.Path == ""
+	return r.InitID == "" && r.Username == "" && r.ProfileID == "" && r.Name == "" && r.Path == "" && r.Type == ""
 }
 
 // Complete returns true if all fields are populated
@@ -60,7 +180,26 @@ func (r Ref) Equals(t Ref) bool {
 		r.Username == t.Username &&
 		r.ProfileID == t.ProfileID &&
 		r.Name == t.Name &&
-		r.Path == t.Path
+		r.Path == t.Path &&
+		r.Type == t.Type
+}
+
+// Matches returns whether the reference is the same as, or a more specific
+// version of, a pattern reference. Empty pattern fields act as wildcards: a
+// pattern without a path matches any version of a dataset, and the empty
+// pattern matches every dataset reference. Type is never a wildcard, because
+// the empty type refers to datasets. Use Equals for strict equality
+func (r Ref) Matches(pattern Ref) bool {
+	return matchField(pattern.InitID, r.InitID) &&
+		matchField(pattern.Username, r.Username) &&
+		matchField(pattern.ProfileID, r.ProfileID) &&
+		matchField(pattern.Name, r.Name) &&
+		matchField(pattern.Path, r.Path) &&
+		pattern.Type == r.Type
+}
+
+func matchField(pattern, value string) bool {
+	return pattern == "" || pattern == value
 }
 
 // Copy duplicates a reference
@@ -71,6 +210,7 @@ func (r Ref) Copy() Ref {
 		ProfileID: r.ProfileID,
 		Name:      r.Name,
 		Path:      r.Path,
+		Type:      r.Type,
 	}
 }
 
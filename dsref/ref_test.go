@@ -97,3 +97,106 @@ func TestRefComplete(t *testing.T) {
 		}
 	}
 }
+
+func TestRefEquals(t *testing.T) {
+	ref := Ref{InitID: "init_id", Username: "a", ProfileID: "QmProfileID", Name: "b", Path: "/ipfs/QmHead"}
+	if !ref.Equals(ref.Copy()) {
+		t.Errorf("expected a reference to equal its copy")
+	}
+
+	// equality is strict, empty fields aren't wildcards
+	cases := []Ref{
+		{},
+		{Username: "a", Name: "b"},
+		{InitID: "init_id", Username: "a", ProfileID: "QmProfileID", Name: "b"},
+		{InitID: "init_id", Username: "a", ProfileID: "QmProfileID", Name: "b", Path: "/ipfs/QmOther"},
+		{InitID: "init_id", Username: "a", ProfileID: "QmProfileID", Name: "b", Path: "/ipfs/QmHead", Type: "collection"},
+	}
+	for _, c := range cases {
+		if ref.Equals(c) || c.Equals(ref) {
+			t.Errorf("expected %#v not to equal %#v", c, ref)
+		}
+	}
+}
+
+func TestRefMatches(t *testing.T) {
+	ref := Ref{InitID: "init_id", Username: "a", ProfileID: "QmProfileID", Name: "b", Path: "/ipfs/QmHead"}
+	cases := []struct {
+		pattern Ref
+		expect  bool
+	}{
+		{Ref{}, true},
+		{ref, true},
+		{Ref{Username: "a", Name: "b"}, true},
+		{Ref{Username: "a", Name: "b", Path: "/ipfs/QmHead"}, true},
+		{Ref{InitID: "init_id"}, true},
+		{Ref{Path: "/ipfs/QmHead"}, true},
+
+		{Ref{Username: "a", Name: "c"}, false},
+		{Ref{Username: "a", Name: "b", Path: "/ipfs/QmOther"}, false},
+		{Ref{InitID: "other_id", Username: "a", Name: "b"}, false},
+		{Ref{Username: "a", Name: "b", Type: "collection"}, false},
+	}
+	for _, c := range cases {
+		if got := ref.Matches(c.pattern); got != c.expect {
+			t.Errorf("pattern %#v: expected match %t, got: %t", c.pattern, c.expect, got)
+		}
+	}
+
+	// a less specific reference doesn't match a more specific pattern
+	if (Ref{Username: "a", Name: "b"}).Matches(ref) {
+		t.Errorf("expected reference without a path not to match a pattern with one")
+	}
+	// the empty type isn't a wildcard
+	if (Ref{Username: "a", Name: "b", Type: "collection"}).Matches(Ref{Username: "a", Name: "b"}) {
+		t.Errorf("expected non-dataset reference not to match a dataset pattern")
+	}
+}
+
+func TestRefCanonical(t *testing.T) {
+	cases := []struct {
+		in     Ref
+		expect string
+	}{
+		{Ref{}, ""},
+		{Ref{Username: "a", Name: "b"}, "a/b"},
+		{Ref{Username: "a"}, "a/"},
+		{Ref{Name: "b"}, "/b"},
+		{Ref{Username: "a", Name: "b", Path: "/ipfs/QmFoo"}, "a/b@/ipfs/QmFoo"},
+		{Ref{Username: "a", Name: "b", ProfileID: "QmProfile"}, "a/b@QmProfile"},
+		{Ref{InitID: "init_id", Username: "a", ProfileID: "QmProfile", Name: "b", Path: "/ipfs/QmFoo"}, "a/b@QmProfile/ipfs/QmFoo#init_id"},
+		{Ref{InitID: "init_id"}, "#init_id"},
+		{Ref{Type: "transform", Username: "a", Name: "b"}, "transform:a/b"},
+		{Ref{Username: "ünïcødé", Name: "データ"}, "ünïcødé/データ"},
+		{Ref{Username: "a@b", Name: "c/d#e"}, "a%40b/c%2Fd%23e"},
+	}
+
+	for _, c := range cases {
+		got := c.in.Canonical()
+		if c.expect != got {
+			t.Errorf("result mismatch. input:%#v \nwant: '%s'\ngot: '%s'", c.in, c.expect, got)
+		}
+		parsed, err := ParseCanonical(got)
+		if err != nil {
+			t.Errorf("parsing canonical form %q: %s", got, err)
+			continue
+		}
+		if !c.in.Equals(parsed) {
+			t.Errorf("round trip mismatch. input:%#v \ngot: %#v", c.in, parsed)
+		}
+	}
+
+	// path variations normalize to the same canonical form
+	for _, p := range []string{"ipfs/QmFoo", "/ipfs/QmFoo", "//ipfs/QmFoo"} {
+		got := Ref{Username: "a", Name: "b", Path: p}.Canonical()
+		if got != "a/b@/ipfs/QmFoo" {
+			t.Errorf("expected path %q to normalize. got: '%s'", p, got)
+		}
+	}
+
+	for _, bad := range []string{"no_separator", "a/b#bad%ZZescape"} {
+		if _, err := ParseCanonical(bad); err == nil {
+			t.Errorf("expected error parsing invalid canonical reference %q", bad)
+		}
+	}
+}
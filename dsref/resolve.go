@@ -3,6 +3,7 @@ package dsref
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
@@ -26,6 +27,25 @@ type Resolver interface {
 	ResolveRef(ctx context.Context, ref *Ref) (source string, err error)
 }
 
+// HeadInfo is lightweight metadata describing the head version of a dataset
+type HeadInfo struct {
+	// Title field from the commit
+	CommitTitle string `json:"commitTitle,omitempty"`
+	// Timestamp field from the commit
+	CommitTime time.Time `json:"commitTime,omitempty"`
+	// Size of the body in bytes
+	BodySize int `json:"bodySize,omitempty"`
+}
+
+// HeadResolver is a Resolver that can also return head metadata for the
+// resolved version, saving callers that only need commit details the cost of
+// loading the dataset in a separate step. head is nil when metadata isn't
+// available, which is not an error
+type HeadResolver interface {
+	Resolver
+	ResolveRefHead(ctx context.Context, ref *Ref) (source string, head *HeadInfo, err error)
+}
+
 // ParallelResolver composes multiple resolvers into one resolver that runs
 // in parallel when called, using the first resolver that doesn't return
 // ErrRefNotFound
@@ -112,3 +132,43 @@ func (sr sequentialResolver) ResolveRef(ctx context.Context, ref *Ref) (string,
 
 	return "", ErrRefNotFound
 }
+
+// NoopResolver returns a resolver that fails to resolve every reference with
+// ErrRefNotFound. It's intended for tests that require a Resolver, but don't
+// expect any references to resolve
+func NoopResolver() Resolver {
+	return noopResolver{}
+}
+
+type noopResolver struct{}
+
+func (noopResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	return "", ErrRefNotFound
+}
+
+// StaticResolver returns a resolver backed by a fixed map of human-friendly
+// "username/name" references to resolved references. References that aren't in
+// the map return ErrRefNotFound. It's intended for tests that need canned
+// resolution results without constructing a repo or logbook
+func StaticResolver(refs map[string]Ref) Resolver {
+	return staticResolver(refs)
+}
+
+type staticResolver map[string]Ref
+
+func (sr staticResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	if ref == nil {
+		return "", ErrRefNotFound
+	}
+	resolved, ok := sr[ref.Human()]
+	if !ok {
+		return "", ErrRefNotFound
+	}
+
+	path := ref.Path
+	*ref = resolved.Copy()
+	if path != "" {
+		ref.Path = path
+	}
+	return "", nil
+}
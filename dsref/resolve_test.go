@@ -1,6 +1,7 @@
 package dsref
 
 import (
+	"context"
 	"testing"
 )
 
@@ -11,3 +12,52 @@ func TestParallelResolver(t *testing.T) {
 func TestSequentialResolver(t *testing.T) {
 	t.Skip("TODO(b5)")
 }
+
+func TestNoopResolver(t *testing.T) {
+	ctx := context.Background()
+	r := NoopResolver()
+	if _, err := r.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); err != ErrRefNotFound {
+		t.Errorf("expected ErrRefNotFound, got: %v", err)
+	}
+	if _, err := r.ResolveRef(ctx, nil); err != ErrRefNotFound {
+		t.Errorf("expected ErrRefNotFound resolving nil, got: %v", err)
+	}
+}
+
+func TestStaticResolver(t *testing.T) {
+	ctx := context.Background()
+	resolved := Ref{
+		InitID:    "init_id",
+		Username:  "a",
+		ProfileID: "QmProfileID",
+		Name:      "b",
+		Path:      "/ipfs/QmHead",
+	}
+	r := StaticResolver(map[string]Ref{"a/b": resolved})
+
+	got := Ref{Username: "a", Name: "b"}
+	if _, err := r.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !resolved.Equals(got) {
+		t.Errorf("result mismatch. expected: %s, got: %s", resolved, got)
+	}
+
+	got = Ref{Username: "a", Name: "b", Path: "/ipfs/QmProvided"}
+	if _, err := r.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "/ipfs/QmProvided" {
+		t.Errorf("expected provided path to be preserved. got: %q", got.Path)
+	}
+	if got.InitID != resolved.InitID {
+		t.Errorf("initID mismatch. expected: %q, got: %q", resolved.InitID, got.InitID)
+	}
+
+	if _, err := r.ResolveRef(ctx, &Ref{Username: "a", Name: "missing"}); err != ErrRefNotFound {
+		t.Errorf("expected ErrRefNotFound, got: %v", err)
+	}
+	if _, err := r.ResolveRef(ctx, nil); err != ErrRefNotFound {
+		t.Errorf("expected ErrRefNotFound resolving nil, got: %v", err)
+	}
+}
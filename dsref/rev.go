@@ -55,6 +55,24 @@ func ParseRev(rev string) (*Rev, error) {
 	return nil, fmt.Errorf("unrecognized revision field: %s", rev)
 }
 
+// ParseVersionAlias interprets a path value as a relative version alias.
+// "HEAD" and "latest" both refer to the most recent version of a history,
+// "HEAD~N" refers to the Nth ancestor of HEAD. ok is false if str isn't a
+// version alias
+func ParseVersionAlias(str string) (gen int, ok bool, err error) {
+	if str == "HEAD" || str == "latest" {
+		return 0, true, nil
+	}
+	if !strings.HasPrefix(str, "HEAD~") {
+		return 0, false, nil
+	}
+	gen, err = strconv.Atoi(strings.TrimPrefix(str, "HEAD~"))
+	if err != nil || gen < 0 {
+		return 0, true, fmt.Errorf("invalid version alias %q: generation must be a non-negative integer", str)
+	}
+	return gen, true, nil
+}
+
 // NewAllRevisions returns a Rev struct that represents all revisions.
 func NewAllRevisions() Rev {
 	return Rev{Field: "ds", Gen: AllGenerations}
@@ -40,6 +40,39 @@ func TestParseRevs(t *testing.T) {
 	}
 }
 
+func TestParseVersionAlias(t *testing.T) {
+	cases := []struct {
+		in  string
+		gen int
+		ok  bool
+		err string
+	}{
+		{"", 0, false, ""},
+		{"/ipfs/QmFoo", 0, false, ""},
+		{"HEAD", 0, true, ""},
+		{"latest", 0, true, ""},
+		{"HEAD~0", 0, true, ""},
+		{"HEAD~1", 1, true, ""},
+		{"HEAD~12", 12, true, ""},
+		{"HEAD~", 0, true, `invalid version alias "HEAD~": generation must be a non-negative integer`},
+		{"HEAD~-1", 0, true, `invalid version alias "HEAD~-1": generation must be a non-negative integer`},
+	}
+
+	for _, c := range cases {
+		gen, ok, err := ParseVersionAlias(c.in)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %q error mismatch. expected: %q, got: %v", c.in, c.err, err)
+			continue
+		}
+		if ok != c.ok {
+			t.Errorf("case %q ok mismatch. expected: %t, got: %t", c.in, c.ok, ok)
+		}
+		if gen != c.gen {
+			t.Errorf("case %q gen mismatch. expected: %d, got: %d", c.in, c.gen, gen)
+		}
+	}
+}
+
 func EnsureRevEqual(a, b *Rev) error {
 	if a.Field != b.Field {
 		return fmt.Errorf("Field: %s != %s", a.Field, b.Field)
@@ -23,8 +23,9 @@ import (
 // PutRefFunc adds a reference to a system that retains references
 // PutRefFunc is required to run the ResolverSpec test, when called the Resolver
 // should retain the reference for later retrieval by the spec test. PutRefFunc
-// also passes the author & oplog that back the reference
-type PutRefFunc func(ref dsref.Ref, author identity.Author, log *oplog.Log) error
+// also passes the author & oplog that back the reference, and the context the
+// spec resolves with
+type PutRefFunc func(ctx context.Context, ref dsref.Ref, author identity.Author, log *oplog.Log) error
 
 // AssertResolverSpec confirms the expected behaviour of a dsref.Resolver
 // Interface implementation. In addition to this test passing, implementations
@@ -56,7 +57,7 @@ func AssertResolverSpec(t *testing.T, r dsref.Resolver, putFunc PutRefFunc) {
 	}
 
 	t.Run("dsrefResolverSpec", func(t *testing.T) {
-		if err := putFunc(expectRef, journal.Author(), log); err != nil {
+		if err := putFunc(ctx, expectRef, journal.Author(), log); err != nil {
 			t.Fatalf("put ref failed: %s", err)
 		}
 
@@ -128,6 +129,20 @@ func AssertResolverSpec(t *testing.T, r dsref.Resolver, putFunc PutRefFunc) {
 		// paths outside of logbook HEAD. Subsystems that store references to
 		// mutable paths (eg: FSI links) cannot be set as reference resolution
 	})
+
+	t.Run("dsrefResolverSpecCancelledContext", func(t *testing.T) {
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		start := time.Now()
+		_, err := r.ResolveRef(cancelledCtx, &dsref.Ref{Username: username, Name: dsname})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected resolving with a cancelled context to return %q, got: %v", context.Canceled, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected resolution to abort promptly with a cancelled context, took %s", elapsed)
+		}
+	})
 }
 
 // ErrResolversInconsistent indicates two resolvers honored a
@@ -0,0 +1,105 @@
+package dsref
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Span records a single stage of reference resolution
+type Span struct {
+	Stage  string
+	Start  time.Time
+	End    time.Time
+	Source string
+	Err    error
+}
+
+// Duration is the length of time the stage took
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Trace collects spans for the stages of a reference resolution. Attach a
+// trace to a context with WithTrace, and wrap resolvers with TraceResolver to
+// record spans
+type Trace struct {
+	// OnSpan is called as each span is recorded, if set. Use OnSpan to forward
+	// spans to an external tracing system
+	OnSpan func(s Span)
+
+	lk    sync.Mutex
+	spans []Span
+}
+
+// Record adds a span to the trace. Record is nil-callable
+func (t *Trace) Record(s Span) {
+	if t == nil {
+		return
+	}
+	t.lk.Lock()
+	t.spans = append(t.spans, s)
+	t.lk.Unlock()
+	if t.OnSpan != nil {
+		t.OnSpan(s)
+	}
+}
+
+// Spans returns recorded spans in the order they completed
+func (t *Trace) Spans() []Span {
+	if t == nil {
+		return nil
+	}
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	spans := make([]Span, len(t.spans))
+	copy(spans, t.spans)
+	return spans
+}
+
+type traceCtxKey struct{}
+
+// WithTrace attaches a trace to a context
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceCtxKey{}, t)
+}
+
+// TraceFromContext returns the trace attached to a context, or nil if the
+// context has no trace
+func TraceFromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceCtxKey{}).(*Trace)
+	return t
+}
+
+// TraceResolver wraps a resolver, recording a span named stage to the trace
+// attached to the resolution context. Resolution is unaffected when the
+// context has no trace
+func TraceResolver(stage string, r Resolver) Resolver {
+	return traceResolver{stage: stage, r: r}
+}
+
+type traceResolver struct {
+	stage string
+	r     Resolver
+}
+
+func (tr traceResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	if tr.r == nil {
+		return "", ErrRefNotFound
+	}
+	t := TraceFromContext(ctx)
+	if t == nil {
+		return tr.r.ResolveRef(ctx, ref)
+	}
+
+	start := time.Now()
+	source, err := tr.r.ResolveRef(ctx, ref)
+	t.Record(Span{
+		Stage:  tr.stage,
+		Start:  start,
+		End:    time.Now(),
+		Source: source,
+		Err:    err,
+	})
+	return source, err
+}
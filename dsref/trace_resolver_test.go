@@ -0,0 +1,68 @@
+package dsref
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slowResolver delays before resolving with a wrapped resolver
+type slowResolver struct {
+	Resolver
+	delay time.Duration
+}
+
+func (sr slowResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	time.Sleep(sr.delay)
+	return sr.Resolver.ResolveRef(ctx, ref)
+}
+
+func TestTraceResolver(t *testing.T) {
+	delay := time.Millisecond * 10
+	local := TraceResolver("local", slowResolver{NoopResolver(), delay})
+	remote := TraceResolver("p2p", slowResolver{StaticResolver(map[string]Ref{
+		"a/b": {InitID: "init_id", Username: "a", Name: "b", Path: "/ipfs/QmHead"},
+	}), delay})
+	r := TraceResolver("resolve", SequentialResolver(local, remote))
+
+	// resolving without a trace records nothing
+	if _, err := r.ResolveRef(context.Background(), &Ref{Username: "a", Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := 0
+	trace := &Trace{OnSpan: func(Span) { exported++ }}
+	ctx := WithTrace(context.Background(), trace)
+	if _, err := r.ResolveRef(ctx, &Ref{Username: "a", Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := trace.Spans()
+	if exported != len(spans) {
+		t.Errorf("expected OnSpan to be called for each of %d spans, got %d calls", len(spans), exported)
+	}
+	expect := []struct {
+		stage string
+		err   error
+		min   time.Duration
+	}{
+		{"local", ErrRefNotFound, delay},
+		{"p2p", nil, delay},
+		{"resolve", nil, delay * 2},
+	}
+	if len(spans) != len(expect) {
+		t.Fatalf("span length mismatch. expected: %d, got: %d", len(expect), len(spans))
+	}
+	for i, e := range expect {
+		s := spans[i]
+		if s.Stage != e.stage {
+			t.Errorf("span %d stage mismatch. expected: %q, got: %q", i, e.stage, s.Stage)
+		}
+		if s.Err != e.err {
+			t.Errorf("span %d error mismatch. expected: %v, got: %v", i, e.err, s.Err)
+		}
+		if s.Duration() < e.min || s.Duration() > time.Second {
+			t.Errorf("span %d duration %s is implausible. expected at least %s", i, s.Duration(), e.min)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package dsref
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TypeResolver dispatches resolution to resolvers registered by reference
+// Type. References with an empty Type are dataset references, and resolve with
+// the dataset resolver. TypeResolver lets applications resolve non-dataset
+// references through the same Resolver machinery, including over the network
+type TypeResolver struct {
+	datasets Resolver
+
+	lk       sync.RWMutex
+	handlers map[string]Resolver
+}
+
+// assert at compile time that TypeResolver is a Resolver
+var _ Resolver = (*TypeResolver)(nil)
+
+// NewTypeResolver creates a TypeResolver that resolves dataset references with
+// the given dataset resolver
+func NewTypeResolver(datasets Resolver) *TypeResolver {
+	return &TypeResolver{
+		datasets: datasets,
+		handlers: map[string]Resolver{},
+	}
+}
+
+// Register adds a resolver for references of a given type. Each type can only
+// be registered once, and the empty string is reserved for datasets
+func (tr *TypeResolver) Register(refType string, r Resolver) error {
+	if refType == "" {
+		return fmt.Errorf("the empty reference type is reserved for datasets")
+	}
+	if r == nil {
+		return fmt.Errorf("resolver for reference type %q is nil", refType)
+	}
+
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+	if _, exists := tr.handlers[refType]; exists {
+		return fmt.Errorf("reference type %q is already registered", refType)
+	}
+	tr.handlers[refType] = r
+	return nil
+}
+
+// ResolveRef dispatches resolution to the resolver registered for ref.Type
+// references of unregistered types return ErrRefNotFound
+func (tr *TypeResolver) ResolveRef(ctx context.Context, ref *Ref) (string, error) {
+	if tr == nil || ref == nil {
+		return "", ErrRefNotFound
+	}
+
+	if ref.Type == "" {
+		if tr.datasets == nil {
+			return "", ErrRefNotFound
+		}
+		return tr.datasets.ResolveRef(ctx, ref)
+	}
+
+	tr.lk.RLock()
+	r, ok := tr.handlers[ref.Type]
+	tr.lk.RUnlock()
+	if !ok {
+		return "", ErrRefNotFound
+	}
+	return r.ResolveRef(ctx, ref)
+}
@@ -0,0 +1,73 @@
+package dsref_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qri-io/qri/dsref"
+	dsrefspec "github.com/qri-io/qri/dsref/spec"
+	"github.com/qri-io/qri/identity"
+	"github.com/qri-io/qri/logbook/oplog"
+)
+
+// artifactResolver resolves references of the "artifact" type for tests
+type artifactResolver map[string]string
+
+func (ar artifactResolver) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
+	path, ok := ar[ref.Name]
+	if !ok {
+		return "", dsref.ErrRefNotFound
+	}
+	ref.Path = path
+	return "", nil
+}
+
+func TestTypeResolver(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := (*dsref.TypeResolver)(nil).ResolveRef(ctx, nil); err != dsref.ErrRefNotFound {
+		t.Errorf("ResolveRef must be nil-callable. expected: %q, got %v", dsref.ErrRefNotFound, err)
+	}
+
+	mem := dsref.NewMemResolver("test_peer_type_resolver")
+	tr := dsref.NewTypeResolver(mem)
+
+	if err := tr.Register("", artifactResolver{}); err == nil {
+		t.Errorf("expected registering the empty type to fail")
+	}
+	if err := tr.Register("artifact", artifactResolver{"model": "/ipfs/QmArtifact"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Register("artifact", artifactResolver{}); err == nil {
+		t.Errorf("expected registering a type twice to fail")
+	}
+
+	ref := dsref.Ref{Type: "artifact", Name: "model"}
+	if _, err := tr.ResolveRef(ctx, &ref); err != nil {
+		t.Fatal(err)
+	}
+	if ref.Path != "/ipfs/QmArtifact" {
+		t.Errorf("path mismatch. expected: %q, got: %q", "/ipfs/QmArtifact", ref.Path)
+	}
+
+	if _, err := tr.ResolveRef(ctx, &dsref.Ref{Type: "unregistered", Name: "model"}); err != dsref.ErrRefNotFound {
+		t.Errorf("expected unregistered type to return ErrRefNotFound, got: %v", err)
+	}
+
+	// untyped references resolve as datasets
+	dsrefspec.AssertResolverSpec(t, tr, func(_ context.Context, ref dsref.Ref, author identity.Author, log *oplog.Log) error {
+		pid, err := identity.KeyIDFromPub(author.AuthorPubKey())
+		if err != nil {
+			return err
+		}
+
+		mem.Put(dsref.VersionInfo{
+			InitID:    ref.InitID,
+			ProfileID: pid,
+			Username:  ref.Username,
+			Name:      ref.Name,
+			Path:      ref.Path,
+		})
+		return nil
+	})
+}
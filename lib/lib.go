@@ -456,7 +456,7 @@ func NewInstance(ctx context.Context, repoPath string, opts ...Option) (qri *Ins
 	}
 
 	if inst.dscache == nil {
-		inst.dscache, err = newDscache(ctx, inst.qfs, inst.bus, pro.Peername, inst.repoPath)
+		inst.dscache, err = newDscache(ctx, inst.qfs, inst.bus, inst.logbook, pro.Peername, inst.repoPath)
 		if err != nil {
 			return nil, fmt.Errorf("newDsache: %w", err)
 		}
@@ -567,9 +567,11 @@ func newLogbook(fs qfs.Filesystem, cfg *config.Config, bus event.Bus, pro *profi
 	return logbook.NewJournal(pro.PrivKey, pro.Peername, bus, fs, logbookPath)
 }
 
-func newDscache(ctx context.Context, fs qfs.Filesystem, bus event.Bus, username, repoPath string) (*dscache.Dscache, error) {
+func newDscache(ctx context.Context, fs qfs.Filesystem, bus event.Bus, book *logbook.Book, username, repoPath string) (*dscache.Dscache, error) {
 	dscachePath := filepath.Join(repoPath, "dscache.qfb")
-	return dscache.NewDscache(ctx, fs, bus, username, dscachePath), nil
+	return dscache.NewDscache(ctx, fs, bus, username, dscachePath, func(o *dscache.Options) {
+		o.Logbook = book
+	}), nil
 }
 
 func newEventBus(ctx context.Context) event.Bus {
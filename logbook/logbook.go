@@ -9,7 +9,10 @@ package logbook
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
 	"sync"
@@ -37,6 +40,15 @@ var (
 	// ErrAccessDenied indicates insufficent privileges to perform a logbook
 	// operation
 	ErrAccessDenied = fmt.Errorf("access denied")
+	// ErrMergeConflict indicates two logbooks hold histories for the same log
+	// that have diverged, and can't be merged without losing operations
+	ErrMergeConflict = fmt.Errorf("logbook: merge conflict")
+	// ErrDatasetDeleted indicates a dataset has been soft-deleted. Its history
+	// is intact, and can be restored with WriteDatasetUndelete
+	ErrDatasetDeleted = fmt.Errorf("logbook: dataset is deleted")
+	// ErrHeadShared indicates the latest version of a dataset has been pushed,
+	// or is referenced by another dataset's history, and can't be rewritten
+	ErrHeadShared = fmt.Errorf("logbook: head version is shared")
 
 	// NewTimestamp generates the current unix nanosecond time.
 	// This is mainly here for tests to override
@@ -45,6 +57,14 @@ var (
 	log = golog.Logger("logbook")
 )
 
+const (
+	// relationDeleted marks a dataset amend operation as a soft delete
+	relationDeleted = "deleted"
+	// relationUndeleted marks a dataset amend operation as restoring a
+	// soft-deleted dataset
+	relationUndeleted = "undeleted"
+)
+
 const (
 	// AuthorModel is the enum for an author model
 	AuthorModel uint32 = iota
@@ -97,8 +117,28 @@ type Book struct {
 	fs         qfs.Filesystem
 
 	publisher event.Publisher
+	// onWriteError is called when persisting the book fails
+	onWriteError WriteErrorHook
+}
+
+// WriteError is returned when a book fails to persist to its filesystem
+type WriteError struct {
+	// Location is the filesystem location the book was writing to
+	Location string
+	Err      error
+}
+
+// Error implements the error interface
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("logbook: writing to %q: %s", e.Location, e.Err)
 }
 
+// Unwrap returns the underlying write error
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// WriteErrorHook is a function called with any error persisting a logbook
+type WriteErrorHook func(ctx context.Context, err *WriteError)
+
 // NewBook creates a book with a user-provided logstore
 func NewBook(pk crypto.PrivKey, store oplog.Logstore) *Book {
 	return &Book{pk: pk, store: store}
@@ -216,17 +256,39 @@ func (book *Book) DeleteAuthor() error {
 }
 
 // save writes the book to book.fsLocation
-func (book *Book) save(ctx context.Context) (err error) {
+func (book *Book) save(ctx context.Context) error {
 	if al, ok := book.store.(oplog.AuthorLogstore); ok {
 		ciphertext, err := al.FlatbufferCipher(book.pk)
 		if err != nil {
-			return err
+			return book.writeError(ctx, err)
 		}
 
 		file := qfs.NewMemfileBytes(book.fsLocation, ciphertext)
-		book.fsLocation, err = book.fs.Put(ctx, file)
+		location, err := book.fs.Put(ctx, file)
+		if err != nil {
+			return book.writeError(ctx, err)
+		}
+		book.fsLocation = location
 	}
-	return err
+	return nil
+}
+
+// SetWriteErrorHook registers a function to call whenever persisting the book
+// fails, replacing any existing hook. Write failures leave the in-memory book
+// ahead of what's stored, so callers can use the hook to alert & stop writing.
+// SetWriteErrorHook isn't safe to call concurrently with writes
+func (book *Book) SetWriteErrorHook(hook WriteErrorHook) {
+	book.onWriteError = hook
+}
+
+// writeError wraps a failure to persist the book, notifying any hook
+func (book *Book) writeError(ctx context.Context, err error) error {
+	werr := &WriteError{Location: book.fsLocation, Err: err}
+	log.Errorf("%s", werr)
+	if book.onWriteError != nil {
+		book.onWriteError(ctx, werr)
+	}
+	return werr
 }
 
 // load reads the book dataset from book.fsLocation
@@ -481,6 +543,132 @@ func (book *Book) WriteDatasetDelete(ctx context.Context, initID string) error {
 	return book.save(ctx)
 }
 
+// WriteDatasetSoftDelete marks a dataset as deleted without closing its log.
+// Soft-deleted datasets are excluded from listings & resolution, but keep
+// their full history and can be restored with WriteDatasetUndelete
+func (book *Book) WriteDatasetSoftDelete(ctx context.Context, initID string) error {
+	if book == nil {
+		return ErrNoLogbook
+	}
+	log.Debugf("WriteDatasetSoftDelete: '%s'", initID)
+
+	dsLog, err := book.datasetLog(ctx, initID)
+	if err != nil {
+		return err
+	}
+	if err := book.hasWriteAccess(dsLog.l); err != nil {
+		return err
+	}
+	if DatasetDeleted(dsLog.l) {
+		return ErrDatasetDeleted
+	}
+
+	dsLog.Append(oplog.Op{
+		Type:      oplog.OpTypeAmend,
+		Model:     DatasetModel,
+		Relations: []string{relationDeleted},
+		Name:      dsLog.l.Name(),
+		Timestamp: NewTimestamp(),
+	})
+
+	err = book.publisher.Publish(ctx, event.ETDatasetDeleteAll, event.DsChange{
+		InitID: initID,
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return book.save(ctx)
+}
+
+// WriteDatasetUndelete restores a soft-deleted dataset
+func (book *Book) WriteDatasetUndelete(ctx context.Context, initID string) error {
+	if book == nil {
+		return ErrNoLogbook
+	}
+	log.Debugf("WriteDatasetUndelete: '%s'", initID)
+
+	dsLog, err := book.datasetLog(ctx, initID)
+	if err != nil {
+		return err
+	}
+	if err := book.hasWriteAccess(dsLog.l); err != nil {
+		return err
+	}
+	if !DatasetDeleted(dsLog.l) {
+		return fmt.Errorf("logbook: dataset %q is not deleted", initID)
+	}
+
+	dsLog.Append(oplog.Op{
+		Type:      oplog.OpTypeAmend,
+		Model:     DatasetModel,
+		Relations: []string{relationUndeleted},
+		Name:      dsLog.l.Name(),
+		Timestamp: NewTimestamp(),
+	})
+
+	authorLog, err := book.authorLog(ctx)
+	if err != nil {
+		return err
+	}
+	err = book.publisher.Publish(ctx, event.ETDatasetNameInit, event.DsChange{
+		InitID:     initID,
+		Username:   book.Username(),
+		ProfileID:  authorLog.ProfileID(),
+		PrettyName: dsLog.l.Name(),
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	if len(dsLog.l.Logs) == 1 {
+		items := branchToLogItems(newBranchLog(dsLog.l.Logs[0]), dsref.Ref{}, 0, -1, false)
+		if len(items) > 0 {
+			err = book.publisher.Publish(ctx, event.ETDatasetCommitChange, event.DsChange{
+				InitID:   initID,
+				TopIndex: len(items),
+				HeadRef:  items[0].Path,
+				Info:     &items[0].VersionInfo,
+			})
+			if err != nil {
+				log.Error(err)
+			}
+		}
+	}
+
+	return book.save(ctx)
+}
+
+// DatasetDeleted reports whether a dataset log is soft-deleted
+func DatasetDeleted(dsLog *oplog.Log) bool {
+	deleted := false
+	for _, op := range dsLog.Ops {
+		if op.Model != DatasetModel || op.Type != oplog.OpTypeAmend || len(op.Relations) != 1 {
+			continue
+		}
+		switch op.Relations[0] {
+		case relationDeleted:
+			deleted = true
+		case relationUndeleted:
+			deleted = false
+		}
+	}
+	return deleted
+}
+
+type includeDeletedCtxKey struct{}
+
+// WithDeleted returns a context that makes resolving a soft-deleted dataset
+// succeed instead of returning ErrDatasetDeleted
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedCtxKey{}, true)
+}
+
+func includeDeleted(ctx context.Context) bool {
+	include, _ := ctx.Value(includeDeletedCtxKey{}).(bool)
+	return include
+}
+
 // WriteVersionSave adds an operation to a log marking the creation of a
 // dataset version. Book will copy details from the provided dataset pointer
 func (book *Book) WriteVersionSave(ctx context.Context, initID string, ds *dataset.Dataset) error {
@@ -569,6 +757,108 @@ func (book *Book) WriteVersionAmend(ctx context.Context, initID string, ds *data
 	return book.save(ctx)
 }
 
+// AmendHead rewrites the commit metadata of the latest version of a dataset
+// in place, without writing a new version. Only the title & timestamp are
+// recorded in logbook, empty fields in meta are left unchanged. AmendHead is
+// meant for fixing mistakes before a version is shared, and fails with
+// ErrHeadShared if the head version has been pushed, or if another dataset's
+// history references the head path. Rewriting an operation invalidates the
+// branch log signature, so the branch log is re-signed
+func (book *Book) AmendHead(ctx context.Context, ref dsref.Ref, meta *dataset.Commit) error {
+	if book == nil {
+		return ErrNoLogbook
+	}
+	if meta == nil {
+		return fmt.Errorf("logbook: commit metadata is required")
+	}
+	log.Debugf("AmendHead: %s", ref)
+
+	initID := ref.InitID
+	if initID == "" {
+		var err error
+		if initID, err = book.RefToInitID(ref); err != nil {
+			return err
+		}
+	}
+	branchLog, err := book.branchLog(ctx, initID)
+	if err != nil {
+		return err
+	}
+	if err := book.hasWriteAccess(branchLog.l); err != nil {
+		return err
+	}
+
+	// find the latest commit operation, noting any pushes that follow it
+	ops := branchLog.l.Ops
+	i := len(ops) - 1
+	pushed := false
+	for ; i >= 0 && ops[i].Model == PushModel; i-- {
+		pushed = true
+	}
+	if i < 0 || ops[i].Model != CommitModel || ops[i].Type == oplog.OpTypeRemove {
+		return fmt.Errorf("%w: dataset %q has no head version to amend", ErrNotFound, initID)
+	}
+	if pushed {
+		return ErrHeadShared
+	}
+	referenced, err := book.pathReferencedOutside(ctx, initID, ops[i].Ref)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return ErrHeadShared
+	}
+
+	if meta.Title != "" {
+		ops[i].Note = meta.Title
+	}
+	if !meta.Timestamp.IsZero() {
+		ops[i].Timestamp = meta.Timestamp.UnixNano()
+	}
+	if err := branchLog.l.Sign(book.pk); err != nil {
+		return err
+	}
+	if err := book.save(ctx); err != nil {
+		return err
+	}
+
+	items := branchToLogItems(branchLog, dsref.Ref{}, 0, -1, false)
+	if len(items) > 0 {
+		err = book.publisher.Publish(ctx, event.ETDatasetCommitChange, event.DsChange{
+			InitID:   initID,
+			TopIndex: len(items),
+			HeadRef:  items[0].Path,
+			Info:     &items[0].VersionInfo,
+		})
+		if err != nil {
+			log.Error(err)
+		}
+	}
+	return nil
+}
+
+// pathReferencedOutside reports if the history of any dataset other than
+// initID references a version path
+func (book *Book) pathReferencedOutside(ctx context.Context, initID, path string) (bool, error) {
+	logs, err := book.ListAllLogs(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, userLog := range logs {
+		for _, dsLog := range userLog.Logs {
+			if dsLog.ID() == initID {
+				continue
+			}
+			paths := map[string]struct{}{}
+			addReferencedPaths(dsLog, paths)
+			if _, ok := paths[path]; ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 // WriteVersionDelete adds an operation to a log marking a number of sequential
 // versions from HEAD as deleted. Because logs are append-only, deletes are
 // recorded as "tombstone" operations that mark removal.
@@ -777,16 +1067,29 @@ func (book Book) Log(ctx context.Context, id string) (*oplog.Log, error) {
 }
 
 // ResolveRef finds the identifier & head path for a dataset reference
-// implements resolve.NameResolver interface
+// implements resolve.NameResolver interface. A ref.Path that is a version
+// alias like "HEAD", "latest", or "HEAD~2" is replaced with the path of the
+// version it refers to. Resolving a soft-deleted dataset returns
+// ErrDatasetDeleted unless the context is created with WithDeleted
 func (book *Book) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
 	if book == nil {
 		return "", dsref.ErrRefNotFound
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	initID, err := book.RefToInitID(*ref)
 	if err != nil {
 		return "", dsref.ErrRefNotFound
 	}
+
+	if !includeDeleted(ctx) {
+		if dsLog, err := book.store.Get(ctx, initID); err == nil && DatasetDeleted(dsLog) {
+			return "", ErrDatasetDeleted
+		}
+	}
 	ref.InitID = initID
 
 	var branchLog *BranchLog
@@ -796,6 +1099,17 @@ func (book *Book) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error
 			return "", err
 		}
 		ref.Path = book.latestSavePath(branchLog.l)
+	} else if gen, isAlias, aliasErr := dsref.ParseVersionAlias(ref.Path); isAlias {
+		if aliasErr != nil {
+			return "", aliasErr
+		}
+		branchLog, err = book.branchLog(ctx, initID)
+		if err != nil {
+			return "", err
+		}
+		if ref.Path, err = versionAliasPath(branchLog, ref.Path, gen); err != nil {
+			return "", err
+		}
 	}
 
 	if ref.ProfileID == "" {
@@ -816,6 +1130,161 @@ func (book *Book) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error
 	return "", nil
 }
 
+// ResolveOpID resolves a logbook operation ID (the hash of an operation) to a
+// reference to the dataset as of that operation. The returned ref's Path is
+// the version that was the head of the dataset when the operation was
+// written. Operations that aren't part of a dataset's history return
+// ErrNotFound
+func (book *Book) ResolveOpID(ctx context.Context, opID string) (dsref.Ref, error) {
+	if book == nil {
+		return dsref.Ref{}, ErrNoLogbook
+	}
+
+	logs, err := book.ListAllLogs(ctx)
+	if err != nil {
+		return dsref.Ref{}, err
+	}
+
+	for _, userLog := range logs {
+		for _, dsLog := range userLog.Logs {
+			if len(dsLog.Logs) != 1 {
+				continue
+			}
+			branchLog := dsLog.Logs[0]
+			ops, ok := opsAsOf(dsLog, branchLog, opID)
+			if !ok {
+				continue
+			}
+			if !includeDeleted(ctx) && DatasetDeleted(dsLog) {
+				return dsref.Ref{}, ErrDatasetDeleted
+			}
+			ref := dsref.Ref{
+				InitID:    dsLog.ID(),
+				Username:  userLog.Name(),
+				ProfileID: userLog.Ops[0].AuthorID,
+				Name:      dsLog.Name(),
+			}
+			items := branchToLogItems(newBranchLog(&oplog.Log{Ops: ops}), dsref.Ref{}, 0, 1, true)
+			if len(items) > 0 {
+				ref.Path = items[0].Path
+			}
+			return ref, nil
+		}
+	}
+	return dsref.Ref{}, fmt.Errorf("%w: operation %q", ErrNotFound, opID)
+}
+
+// ResolveRefAt resolves a reference to the latest version of a dataset
+// committed at or before t, ignoring any path the reference has. Resolving a
+// time before the first version of a dataset returns dsref.ErrRefNotFound
+func (book *Book) ResolveRefAt(ctx context.Context, ref *dsref.Ref, t time.Time) (string, error) {
+	if book == nil || ref == nil {
+		return "", dsref.ErrRefNotFound
+	}
+
+	resolved := ref.Copy()
+	resolved.Path = ""
+	if _, err := book.ResolveRef(ctx, &resolved); err != nil {
+		return "", err
+	}
+	branchLog, err := book.branchLog(ctx, resolved.InitID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, item := range branchToLogItems(branchLog, dsref.Ref{}, 0, -1, true) {
+		if !item.CommitTime.After(t) {
+			resolved.Path = item.Path
+			*ref = resolved
+			return "", nil
+		}
+	}
+	return "", fmt.Errorf("%w: no version of %q at or before %s", dsref.ErrRefNotFound, resolved.Human(), t.Format(time.RFC3339))
+}
+
+// Descends reports whether remote is a later version of the same dataset
+// than local, according to the dataset history in the book. Descends has the
+// signature of a dsref.DescendsFunc. The book only checks history it holds,
+// so source is ignored: versions from a remote log must be merged into the
+// book to be found. Versions missing from the history report false
+func (book *Book) Descends(ctx context.Context, local, remote dsref.Ref, source string) (bool, error) {
+	if book == nil {
+		return false, ErrNoLogbook
+	}
+	if local.InitID == "" || local.InitID != remote.InitID || local.Path == remote.Path {
+		return false, nil
+	}
+	branchLog, err := book.branchLog(ctx, local.InitID)
+	if err != nil {
+		return false, err
+	}
+
+	// items are ordered newest first, the remote version must come before the
+	// local one
+	localIdx, remoteIdx := -1, -1
+	for i, item := range branchToLogItems(branchLog, dsref.Ref{}, 0, -1, true) {
+		if item.Path == local.Path && localIdx < 0 {
+			localIdx = i
+		} else if item.Path == remote.Path && remoteIdx < 0 {
+			remoteIdx = i
+		}
+	}
+	return remoteIdx >= 0 && localIdx > remoteIdx, nil
+}
+
+// opsAsOf returns the branch operations written up to & including the
+// operation with hash opID. Operations on the dataset log are placed in branch
+// history by timestamp. ok is false if neither log contains the operation
+func opsAsOf(dsLog, branchLog *oplog.Log, opID string) (ops []oplog.Op, ok bool) {
+	for i, op := range branchLog.Ops {
+		if op.Hash() == opID {
+			return branchLog.Ops[:i+1], true
+		}
+	}
+	for _, op := range dsLog.Ops {
+		if op.Hash() != opID {
+			continue
+		}
+		for i, bop := range branchLog.Ops {
+			if bop.Timestamp > op.Timestamp {
+				return branchLog.Ops[:i], true
+			}
+		}
+		return branchLog.Ops, true
+	}
+	return nil, false
+}
+
+// HeadCache caches the resolved heads of datasets, keyed by InitID
+type HeadCache interface {
+	InvalidateHead(initID string)
+}
+
+// SubscribeHeadCache keeps a head cache in sync with logbook writes,
+// invalidating the cached head of a dataset whenever a book publishing to bus
+// changes the dataset's head, renames it, or deletes it
+func SubscribeHeadCache(bus event.Bus, cache HeadCache) {
+	bus.Subscribe(func(_ context.Context, _ event.Type, payload interface{}) error {
+		if change, ok := payload.(event.DsChange); ok && change.InitID != "" {
+			cache.InvalidateHead(change.InitID)
+		}
+		return nil
+	},
+		event.ETDatasetCommitChange,
+		event.ETDatasetRename,
+		event.ETDatasetDeleteAll)
+}
+
+// versionAliasPath returns the path of the nth-generational ancestor of
+// the latest version in a branch
+func versionAliasPath(blog *BranchLog, alias string, gen int) (string, error) {
+	items := branchToLogItems(blog, dsref.Ref{}, 0, -1, true)
+	if gen >= len(items) {
+		return "", fmt.Errorf("%w: version alias %q exceeds history length of %d", ErrNotFound, alias, len(items))
+	}
+	return items[gen].Path, nil
+}
+
 func (book *Book) latestSavePath(branchLog *oplog.Log) string {
 	removes := 0
 
@@ -913,6 +1382,130 @@ func (book Book) LogBytes(log *oplog.Log) ([]byte, error) {
 	return log.FlatbufferBytes(), nil
 }
 
+// ExportDataset writes the full operation history for a single dataset to w
+// as a portable, signed flatbuffer. Every log in the exported user, dataset,
+// and branch hierarchy is signed with this book's private key, allowing
+// anyone with the author's public key to verify the export with
+// VerifyExportedLog.
+//
+// Log signatures only cover operation references, so the export is prefixed
+// with a signature of the full contents of every operation in the hierarchy:
+// a uvarint signature length, followed by the signature, followed by the
+// flatbuffer-encoded log
+func (book *Book) ExportDataset(ctx context.Context, ref dsref.Ref, w io.Writer) error {
+	if book == nil {
+		return ErrNoLogbook
+	}
+
+	initID := ref.InitID
+	if initID == "" {
+		var err error
+		if initID, err = book.RefToInitID(ref); err != nil {
+			return err
+		}
+	}
+
+	lg, err := book.UserDatasetBranchesLog(ctx, initID)
+	if err != nil {
+		return err
+	}
+
+	// sign a copy, leaving signatures in the store untouched
+	lg = lg.DeepCopy()
+	if err := signLogTree(lg, book.pk); err != nil {
+		return err
+	}
+	sig, err := book.pk.Sign(lg.TreeSigningBytes())
+	if err != nil {
+		return err
+	}
+
+	sigLen := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(sigLen, uint64(len(sig)))
+	for _, data := range [][]byte{sigLen[:n], sig, lg.FlatbufferBytes()} {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func signLogTree(lg *oplog.Log, pk crypto.PrivKey) error {
+	if err := lg.Sign(pk); err != nil {
+		return err
+	}
+	for _, l := range lg.Logs {
+		if err := signLogTree(l, pk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyExportedLog reads a log written by ExportDataset, confirming the log
+// is authored by the holder of the private key for pub, that no operation in
+// the exported hierarchy has been changed, and that every log in the
+// hierarchy carries a valid signature
+func VerifyExportedLog(r io.Reader, pub crypto.PubKey) (*oplog.Log, error) {
+	if pub == nil {
+		return nil, fmt.Errorf("logbook: public key is required")
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("logbook: exported log is empty")
+	}
+
+	sigLen, n := binary.Uvarint(data)
+	if n <= 0 || sigLen > uint64(len(data)-n) {
+		return nil, fmt.Errorf("logbook: exported log has a malformed signature")
+	}
+	sig := data[n : n+int(sigLen)]
+	data = data[n+int(sigLen):]
+
+	lg, err := oplog.FromFlatbufferBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("logbook: reading exported log: %w", err)
+	}
+	if len(lg.Ops) == 0 || lg.Model() != AuthorModel {
+		return nil, fmt.Errorf("logbook: exported log isn't rooted as an author")
+	}
+
+	keyID, err := identity.KeyIDFromPub(pub)
+	if err != nil {
+		return nil, err
+	}
+	if authorID := lg.FirstOpAuthorID(); authorID != keyID {
+		return nil, fmt.Errorf("%w: exported log author %q doesn't match public key %q", ErrAccessDenied, authorID, keyID)
+	}
+
+	ok, err := pub.Verify(lg.TreeSigningBytes(), sig)
+	if err != nil {
+		return nil, fmt.Errorf("logbook: verifying exported log: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("logbook: exported log has an invalid signature")
+	}
+	if err := verifyLogTree(lg, pub); err != nil {
+		return nil, err
+	}
+	return lg, nil
+}
+
+func verifyLogTree(lg *oplog.Log, pub crypto.PubKey) error {
+	if err := lg.Verify(pub); err != nil {
+		return fmt.Errorf("logbook: verifying log %q: %w", lg.ID(), err)
+	}
+	for _, l := range lg.Logs {
+		if err := verifyLogTree(l, pub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DsrefAliasForLog parses log data into a dataset alias reference, populating
 // only the username and name components of a dataset.
 // the passed in oplog must refer unambiguously to a dataset or branch.
@@ -937,7 +1530,42 @@ func DsrefAliasForLog(log *oplog.Log) (dsref.Ref, error) {
 	return ref, nil
 }
 
-// MergeLog adds a log to the logbook, merging with any existing log data
+// DatasetMergeFailure describes a dataset log that couldn't be merged
+type DatasetMergeFailure struct {
+	InitID string
+	Name   string
+	Err    error
+}
+
+// MergeError is returned by MergeLog when some dataset logs fail to merge.
+// Dataset logs that aren't listed in Failures were merged
+type MergeError struct {
+	Failures []DatasetMergeFailure
+}
+
+// Error implements the error interface
+func (e *MergeError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = fmt.Sprintf("%s (%s): %s", f.Name, f.InitID, f.Err)
+	}
+	return fmt.Sprintf("logbook: %d dataset logs failed to merge: %s", len(e.Failures), strings.Join(msgs, "; "))
+}
+
+// Failed reports whether the dataset log with the given InitID failed to merge
+func (e *MergeError) Failed(initID string) bool {
+	for _, f := range e.Failures {
+		if f.InitID == initID {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeLog adds a log to the logbook, merging with any existing log data.
+// Each dataset log in an author log is merged independently, a dataset log
+// that fails to merge doesn't block merging the others. If any dataset logs
+// fail MergeLog returns a *MergeError listing them
 func (book *Book) MergeLog(ctx context.Context, sender identity.Author, lg *oplog.Log) error {
 	if book == nil {
 		return ErrNoLogbook
@@ -950,11 +1578,165 @@ func (book *Book) MergeLog(ctx context.Context, sender identity.Author, lg *oplo
 		return err
 	}
 
-	if err := book.store.MergeLog(ctx, lg); err != nil {
+	if len(lg.Ops) == 0 || lg.Model() != AuthorModel {
+		if err := book.store.MergeLog(ctx, lg); err != nil {
+			return err
+		}
+		return book.save(ctx)
+	}
+
+	author := &oplog.Log{ParentID: lg.ParentID, Signature: lg.Signature, Ops: lg.Ops}
+	if err := book.store.MergeLog(ctx, author); err != nil {
+		return err
+	}
+	var failures []DatasetMergeFailure
+	for _, dsLog := range lg.Logs {
+		if err := book.mergeDatasetLog(ctx, author, dsLog); err != nil {
+			f := DatasetMergeFailure{InitID: dsLog.ID(), Err: err}
+			if len(dsLog.Ops) > 0 {
+				f.Name = dsLog.Name()
+			}
+			log.Debugf("merging dataset log %q: %s", f.InitID, err)
+			failures = append(failures, f)
+		}
+	}
+
+	if err := book.save(ctx); err != nil {
 		return err
 	}
+	if len(failures) > 0 {
+		return &MergeError{Failures: failures}
+	}
+	return nil
+}
 
-	return book.save(ctx)
+// mergeDatasetLog merges a single dataset log into the store under its author.
+// The merge is first applied to a copy of any local history for the dataset,
+// leaving the store untouched if the merged history is unusable
+func (book *Book) mergeDatasetLog(ctx context.Context, author, dsLog *oplog.Log) error {
+	if len(dsLog.Ops) == 0 {
+		return fmt.Errorf("%w: dataset log has no operations", ErrLogTooShort)
+	}
+	for _, branch := range dsLog.Logs {
+		if len(branch.Ops) == 0 {
+			return fmt.Errorf("%w: branch log has no operations", ErrLogTooShort)
+		}
+	}
+
+	merged := &oplog.Log{}
+	if local, err := book.store.Get(ctx, dsLog.ID()); err == nil {
+		merged = local.DeepCopy()
+	} else if !errors.Is(err, oplog.ErrNotFound) {
+		return err
+	}
+	merged.Merge(dsLog.DeepCopy())
+	if err := merged.VerifySeq(); err != nil {
+		return fmt.Errorf("merged history: %w", err)
+	}
+	for _, branch := range merged.Logs {
+		if err := branch.VerifySeq(); err != nil {
+			return fmt.Errorf("merged history of branch %q: %w", branch.Name(), err)
+		}
+	}
+
+	return book.store.MergeLog(ctx, &oplog.Log{Ops: author.Ops, Logs: []*oplog.Log{dsLog}})
+}
+
+// PullDataset fetches the operation history for a single dataset from remote,
+// merging it into this book. Only the author, dataset, and branch logs for
+// the dataset's InitID are transferred. Pulling a history that has diverged
+// from the local copy fails with ErrMergeConflict
+func (book *Book) PullDataset(ctx context.Context, remote *Book, ref dsref.Ref) error {
+	if book == nil || remote == nil {
+		return ErrNoLogbook
+	}
+
+	lg, err := remote.datasetTransferLog(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := book.checkMergeConflict(ctx, lg); err != nil {
+		return err
+	}
+	return book.MergeLog(ctx, remote.Author(), lg)
+}
+
+// PushDataset sends the operation history for a single dataset to remote,
+// merging it into the remote book. Only the author, dataset, and branch logs
+// for the dataset's InitID are transferred. Pushing a history that has
+// diverged from the remote copy fails with ErrMergeConflict
+func (book *Book) PushDataset(ctx context.Context, remote *Book, ref dsref.Ref) error {
+	if book == nil || remote == nil {
+		return ErrNoLogbook
+	}
+	return remote.PullDataset(ctx, book, ref)
+}
+
+// datasetTransferLog returns a signed copy of the log hierarchy for a single
+// dataset, suitable for merging into another book
+func (book *Book) datasetTransferLog(ctx context.Context, ref dsref.Ref) (*oplog.Log, error) {
+	initID := ref.InitID
+	if initID == "" {
+		var err error
+		if initID, err = book.RefToInitID(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	lg, err := book.UserDatasetBranchesLog(ctx, initID)
+	if err != nil {
+		return nil, err
+	}
+
+	// sign a copy, leaving signatures in the store untouched
+	lg = lg.DeepCopy()
+	if err := signLogTree(lg, book.pk); err != nil {
+		return nil, err
+	}
+	return lg, nil
+}
+
+// checkMergeConflict confirms the dataset logs in incoming can be merged into
+// the book without dropping operations. Logs conflict when neither history
+// is a prefix of the other
+func (book *Book) checkMergeConflict(ctx context.Context, incoming *oplog.Log) error {
+	for _, dsLog := range incoming.Logs {
+		local, err := book.store.Get(ctx, dsLog.ID())
+		if errors.Is(err, oplog.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := logsConflict(local, dsLog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func logsConflict(local, incoming *oplog.Log) error {
+	n := len(local.Ops)
+	if len(incoming.Ops) < n {
+		n = len(incoming.Ops)
+	}
+	for i := 0; i < n; i++ {
+		if !local.Ops[i].Equal(incoming.Ops[i]) {
+			return fmt.Errorf("%w: log %q diverges at operation %d", ErrMergeConflict, local.Name(), i)
+		}
+	}
+
+	// match children the same way oplog.Log.Merge does, by initialization op
+	for _, x := range incoming.Logs {
+		for _, y := range local.Logs {
+			if len(x.Ops) > 0 && len(y.Ops) > 0 && x.Ops[0].Equal(y.Ops[0]) {
+				if err := logsConflict(y, x); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
 }
 
 // RemoveLog removes an entire log from a logbook
@@ -1,8 +1,10 @@
 package logbook_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -235,6 +237,12 @@ func TestNilCallable(t *testing.T) {
 	if err = book.WriteVersionAmend(ctx, initID, nil); err != logbook.ErrNoLogbook {
 		t.Errorf("expected '%s', got: %v", logbook.ErrNoLogbook, err)
 	}
+	if err = book.AmendHead(ctx, dsref.Ref{}, nil); err != logbook.ErrNoLogbook {
+		t.Errorf("expected '%s', got: %v", logbook.ErrNoLogbook, err)
+	}
+	if _, err = book.Descends(ctx, dsref.Ref{}, dsref.Ref{}, ""); err != logbook.ErrNoLogbook {
+		t.Errorf("expected '%s', got: %v", logbook.ErrNoLogbook, err)
+	}
 	if err = book.WriteVersionDelete(ctx, initID, 0); err != logbook.ErrNoLogbook {
 		t.Errorf("expected '%s', got: %v", logbook.ErrNoLogbook, err)
 	}
@@ -255,11 +263,279 @@ func TestResolveRef(t *testing.T) {
 	}
 
 	book := tr.Book
-	dsrefspec.AssertResolverSpec(t, book, func(ref dsref.Ref, author identity.Author, log *oplog.Log) error {
-		return book.MergeLog(context.Background(), author, log)
+	dsrefspec.AssertResolverSpec(t, book, func(ctx context.Context, ref dsref.Ref, author identity.Author, log *oplog.Log) error {
+		return book.MergeLog(ctx, author, log)
 	})
 }
 
+func TestResolveRefVersionAlias(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	initID := tr.WriteWorldBankExample(t)
+	tr.WriteMoreWorldBankCommits(t, initID)
+	book := tr.Book
+
+	cases := []struct {
+		alias, expect string
+	}{
+		{"HEAD", "QmHashOfVersion5"},
+		{"latest", "QmHashOfVersion5"},
+		{"HEAD~1", "QmHashOfVersion4"},
+		{"HEAD~2", "QmHashOfVersion3"},
+	}
+
+	for _, c := range cases {
+		ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population", Path: c.alias}
+		if _, err := book.ResolveRef(tr.Ctx, &ref); err != nil {
+			t.Errorf("alias %q unexpected error: %s", c.alias, err)
+			continue
+		}
+		if ref.Path != c.expect {
+			t.Errorf("alias %q path mismatch. expected: %q, got: %q", c.alias, c.expect, ref.Path)
+		}
+	}
+
+	ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population", Path: "HEAD~3"}
+	_, err := book.ResolveRef(tr.Ctx, &ref)
+	if !errors.Is(err, logbook.ErrNotFound) {
+		t.Errorf("expected out-of-range alias to return ErrNotFound, got: %v", err)
+	}
+	expectErr := `logbook: not found: version alias "HEAD~3" exceeds history length of 3`
+	if err != nil && err.Error() != expectErr {
+		t.Errorf("error message mismatch. expected: %q, got: %q", expectErr, err)
+	}
+}
+
+func TestResolveOpID(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	if _, err := (*logbook.Book)(nil).ResolveOpID(tr.Ctx, "foo"); err != logbook.ErrNoLogbook {
+		t.Errorf("book ResolveOpID must be nil-callable. expected: %q, got %v", logbook.ErrNoLogbook, err)
+	}
+
+	initID := tr.WriteWorldBankExample(t)
+	tr.WriteMoreWorldBankCommits(t, initID)
+	book := tr.Book
+
+	branchLog, err := book.BranchRef(tr.Ctx, dsref.Ref{Username: tr.Username, Name: "world_bank_population"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// expected path of the dataset as of each commit operation, keyed by the
+	// op's reference. removing QmHashOfVersion2 resets the head to
+	// QmHashOfVersion1
+	expect := map[string]string{
+		"QmHashOfVersion1": "QmHashOfVersion1",
+		"QmHashOfVersion2": "QmHashOfVersion2",
+		"":                 "QmHashOfVersion1",
+		"QmHashOfVersion4": "QmHashOfVersion4",
+	}
+	checked := 0
+	for _, op := range branchLog.Ops {
+		path, ok := expect[op.Ref]
+		if op.Model != logbook.CommitModel || !ok {
+			continue
+		}
+		checked++
+		got, err := book.ResolveOpID(tr.Ctx, op.Hash())
+		if err != nil {
+			t.Errorf("resolving op %q: %s", op.Ref, err)
+			continue
+		}
+		if got.InitID != initID || got.Username != tr.Username || got.Name != "world_bank_population" {
+			t.Errorf("op %q resolved to wrong dataset: %s", op.Ref, got)
+		}
+		if got.Path != path {
+			t.Errorf("op %q path mismatch. expected: %q, got: %q", op.Ref, path, got.Path)
+		}
+	}
+	if checked != 4 {
+		t.Errorf("expected to check 4 operations, checked %d", checked)
+	}
+
+	if _, err := book.ResolveOpID(tr.Ctx, "not_an_op_id"); !errors.Is(err, logbook.ErrNotFound) {
+		t.Errorf("expected unknown operation to return ErrNotFound, got: %v", err)
+	}
+}
+
+func TestResolveRefAt(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	initID := tr.WriteWorldBankExample(t)
+	tr.WriteMoreWorldBankCommits(t, initID)
+	book := tr.Book
+
+	// after collapsing deletes & amends, history is QmHashOfVersion3 on
+	// January 3rd, QmHashOfVersion4 on the 4th, and QmHashOfVersion5 on the 5th
+	cases := []struct {
+		description string
+		t           time.Time
+		expect      string
+	}{
+		{"on first commit", time.Date(2000, time.January, 3, 0, 0, 0, 0, time.UTC), "QmHashOfVersion3"},
+		{"between commits", time.Date(2000, time.January, 4, 12, 0, 0, 0, time.UTC), "QmHashOfVersion4"},
+		{"on commit boundary", time.Date(2000, time.January, 4, 0, 0, 0, 0, time.UTC), "QmHashOfVersion4"},
+		{"after last commit", time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), "QmHashOfVersion5"},
+	}
+
+	for _, c := range cases {
+		ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population", Path: "QmHashOfVersion5"}
+		if _, err := book.ResolveRefAt(tr.Ctx, &ref, c.t); err != nil {
+			t.Errorf("%s: unexpected error: %s", c.description, err)
+			continue
+		}
+		if ref.Path != c.expect {
+			t.Errorf("%s: path mismatch. expected: %q, got: %q", c.description, c.expect, ref.Path)
+		}
+		if ref.InitID != initID {
+			t.Errorf("%s: expected InitID %q, got: %q", c.description, initID, ref.InitID)
+		}
+	}
+
+	ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population"}
+	before := time.Date(1999, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if _, err := book.ResolveRefAt(tr.Ctx, &ref, before); !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Errorf("expected time before first commit to return ErrRefNotFound, got: %v", err)
+	}
+	if ref.Path != "" {
+		t.Errorf("expected failed resolution to leave ref unchanged, got path: %q", ref.Path)
+	}
+}
+
+func TestAmendHead(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	initID := tr.WriteWorldBankExample(t)
+	tr.WriteMoreWorldBankCommits(t, initID)
+	book := tr.Book
+	ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population"}
+
+	before, err := book.Items(tr.Ctx, ref, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := book.AmendHead(tr.Ctx, ref, &dataset.Commit{Title: "v5, typo fixed"}); err != nil {
+		t.Fatalf("amending head: %s", err)
+	}
+
+	after, err := book.Items(tr.Ctx, ref, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("expected amending not to add a version. expected %d versions, got: %d", len(before), len(after))
+	}
+	if after[0].CommitTitle != "v5, typo fixed" {
+		t.Errorf("head title mismatch. expected: %q, got: %q", "v5, typo fixed", after[0].CommitTitle)
+	}
+	if !after[0].CommitTime.Equal(before[0].CommitTime) {
+		t.Errorf("expected commit time to be unchanged. expected: %s, got: %s", before[0].CommitTime, after[0].CommitTime)
+	}
+	for i := range after {
+		if after[i].Path != before[i].Path {
+			t.Errorf("version %d path mismatch. expected: %q, got: %q", i, before[i].Path, after[i].Path)
+		}
+	}
+
+	resolved := ref.Copy()
+	if _, err := book.ResolveRef(tr.Ctx, &resolved); err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Path != "QmHashOfVersion5" {
+		t.Errorf("expected head to resolve to QmHashOfVersion5, got: %q", resolved.Path)
+	}
+
+	branchLog, err := book.BranchRef(tr.Ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := branchLog.Verify(book.AuthorPubKey()); err != nil {
+		t.Errorf("expected amended branch log to be re-signed: %s", err)
+	}
+
+	// another dataset referencing the head path shares it
+	otherID, err := book.WriteDatasetInit(tr.Ctx, "fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = book.WriteVersionSave(tr.Ctx, otherID, &dataset.Dataset{
+		Peername: tr.Username,
+		Name:     "fork",
+		Commit:   &dataset.Commit{Timestamp: time.Date(2000, time.January, 6, 0, 0, 0, 0, time.UTC), Title: "fork"},
+		Path:     "QmHashOfVersion5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := book.AmendHead(tr.Ctx, ref, &dataset.Commit{Title: "again"}); !errors.Is(err, logbook.ErrHeadShared) {
+		t.Errorf("expected amending a head referenced by another dataset to fail with ErrHeadShared, got: %v", err)
+	}
+
+	// a pushed head is shared
+	forkRef := dsref.Ref{Username: tr.Username, Name: "fork", InitID: otherID}
+	if _, _, err := book.WriteRemotePush(tr.Ctx, otherID, 1, "registry.qri.cloud"); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.AmendHead(tr.Ctx, forkRef, &dataset.Commit{Title: "again"}); !errors.Is(err, logbook.ErrHeadShared) {
+		t.Errorf("expected amending a pushed head to fail with ErrHeadShared, got: %v", err)
+	}
+
+	// datasets without versions have no head to amend
+	emptyID, err := book.WriteDatasetInit(tr.Ctx, "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyRef := dsref.Ref{Username: tr.Username, Name: "empty", InitID: emptyID}
+	if err := book.AmendHead(tr.Ctx, emptyRef, &dataset.Commit{Title: "nope"}); !errors.Is(err, logbook.ErrNotFound) {
+		t.Errorf("expected amending a dataset without versions to fail with ErrNotFound, got: %v", err)
+	}
+}
+
+func TestDescends(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	initID := tr.WriteWorldBankExample(t)
+	tr.WriteMoreWorldBankCommits(t, initID)
+	items, err := tr.Book.Items(tr.Ctx, tr.WorldBankRef(), 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) < 2 {
+		t.Fatalf("expected multiple versions, got: %d", len(items))
+	}
+	head := dsref.Ref{InitID: initID, Path: items[0].Path}
+	prev := dsref.Ref{InitID: initID, Path: items[1].Path}
+
+	cases := []struct {
+		description   string
+		local, remote dsref.Ref
+		expect        bool
+	}{
+		{"remote ahead", prev, head, true},
+		{"local ahead", head, prev, false},
+		{"same version", head, head, false},
+		{"unknown remote version", prev, dsref.Ref{InitID: initID, Path: "/ipfs/QmUnknown"}, false},
+		{"different dataset", prev, dsref.Ref{InitID: "other_init_id", Path: head.Path}, false},
+	}
+	for _, c := range cases {
+		got, err := tr.Book.Descends(tr.Ctx, c.local, c.remote, "")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.description, err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("%s: expected %t, got %t", c.description, c.expect, got)
+		}
+	}
+}
+
 func TestBookLogEntries(t *testing.T) {
 	tr, cleanup := newTestRunner(t)
 	defer cleanup()
@@ -702,6 +978,430 @@ func TestLogTransfer(t *testing.T) {
 // thus they have the same profileID but a different userCreateID. Then they push again to the
 // same remote. Test that a client is able to pull this new dataset, and it will merge into their
 // logbook, instead of creating two entries for the same user.
+func TestExportDataset(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	tr.WriteWorldBankExample(t)
+	tr.WriteRenameExample(t)
+
+	if err := (*logbook.Book)(nil).ExportDataset(tr.Ctx, tr.WorldBankRef(), &bytes.Buffer{}); err != logbook.ErrNoLogbook {
+		t.Errorf("expected nil book to return ErrNoLogbook, got: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tr.Book.ExportDataset(tr.Ctx, dsref.Ref{Username: tr.Username, Name: "world_bank_population"}, buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// a third party only needs the author's public key to verify
+	pub := tr.Book.AuthorPubKey()
+	lg, err := logbook.VerifyExportedLog(bytes.NewReader(data), pub)
+	if err != nil {
+		t.Fatalf("verifying exported log: %s", err)
+	}
+	if len(lg.Logs) != 1 {
+		t.Errorf("expected export to contain exactly one dataset, got: %d", len(lg.Logs))
+	}
+
+	foreign := tr.foreignLogbook(t, "third_party")
+	author := identity.NewAuthor(tr.Book.AuthorID(), pub, tr.Username)
+	if err := foreign.MergeLog(tr.Ctx, author, lg); err != nil {
+		t.Fatalf("merging exported log into fresh journal: %s", err)
+	}
+
+	expect, err := tr.Book.Items(tr.Ctx, tr.WorldBankRef(), 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := foreign.Items(tr.Ctx, tr.WorldBankRef(), 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("items mismatch (-want +got):\n%s", diff)
+	}
+
+	// a different key must fail verification
+	if _, err := logbook.VerifyExportedLog(bytes.NewReader(data), testPrivKey2(t).GetPublic()); !errors.Is(err, logbook.ErrAccessDenied) {
+		t.Errorf("expected verifying with the wrong key to return ErrAccessDenied, got: %v", err)
+	}
+
+	// tampering with any field of an operation in a nested log must fail
+	// verification. replacements keep the flatbuffer well-formed
+	commitTime := make([]byte, 8)
+	binary.LittleEndian.PutUint64(commitTime, uint64(time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC).UnixNano()))
+	laterTime := make([]byte, 8)
+	binary.LittleEndian.PutUint64(laterTime, uint64(time.Date(2001, time.January, 2, 0, 0, 0, 0, time.UTC).UnixNano()))
+
+	tamperCases := []struct {
+		description string
+		orig, repl  []byte
+	}{
+		{"ref", []byte("QmHashOfVersion2"), []byte("QmTamperedPath02")},
+		{"note", []byte("added body data"), []byte("added evil data")},
+		{"timestamp", commitTime, laterTime},
+	}
+	for _, c := range tamperCases {
+		if !bytes.Contains(data, c.orig) {
+			t.Fatalf("tampering %s: export doesn't contain %q", c.description, c.orig)
+		}
+		tampered := bytes.Replace(data, c.orig, c.repl, -1)
+		if _, err := logbook.VerifyExportedLog(bytes.NewReader(tampered), pub); err == nil {
+			t.Errorf("expected export with tampered %s to fail verification", c.description)
+		}
+	}
+
+	// a truncated signature must fail verification
+	if _, err := logbook.VerifyExportedLog(bytes.NewReader(data[:8]), pub); err == nil {
+		t.Errorf("expected truncated export to fail verification")
+	}
+}
+
+func TestPushPullDataset(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	initID := tr.WriteWorldBankExample(t)
+	tr.WriteRenameExample(t)
+
+	if err := (*logbook.Book)(nil).PullDataset(tr.Ctx, tr.Book, tr.WorldBankRef()); err != logbook.ErrNoLogbook {
+		t.Errorf("expected nil book to return ErrNoLogbook, got: %v", err)
+	}
+
+	foreign := tr.foreignLogbook(t, "collaborator")
+	if err := foreign.PullDataset(tr.Ctx, tr.Book, dsref.Ref{Username: tr.Username, Name: "world_bank_population"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect, err := tr.Book.Items(tr.Ctx, tr.WorldBankRef(), 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := foreign.Items(tr.Ctx, tr.WorldBankRef(), 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("pulled items mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := foreign.RefToInitID(tr.RenameRef()); !errors.Is(err, logbook.ErrNotFound) {
+		t.Errorf("expected pull to only transfer one dataset. resolving other dataset error: %v", err)
+	}
+
+	tr.WriteMoreWorldBankCommits(t, initID)
+	if err := tr.Book.PushDataset(tr.Ctx, foreign, tr.WorldBankRef()); err != nil {
+		t.Fatal(err)
+	}
+	got, err = foreign.Items(tr.Ctx, tr.WorldBankRef(), 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect, err = tr.Book.Items(tr.Ctx, tr.WorldBankRef(), 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("pushed items mismatch (-want +got):\n%s", diff)
+	}
+
+	// a second journal for the same author that writes its own version
+	// diverges from the original. Rewinding the clock recreates the author's
+	// initialization op, giving both journals write access to the same logs
+	tr.Tick = 0
+	diverged, err := logbook.NewJournal(testPrivKey(t), tr.Username, event.NilBus, qfs.NewMemFS(), "/mem/logbook.qfb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diverged.PullDataset(tr.Ctx, tr.Book, tr.WorldBankRef()); err != nil {
+		t.Fatal(err)
+	}
+	if err := diverged.WriteVersionSave(tr.Ctx, initID, &dataset.Dataset{
+		Peername: tr.Username,
+		Name:     "world_bank_population",
+		Commit: &dataset.Commit{
+			Timestamp: time.Date(2000, time.January, 6, 0, 0, 0, 0, time.UTC),
+			Title:     "diverged v6",
+		},
+		Path:         "QmHashOfDivergedVersion6",
+		PreviousPath: "QmHashOfVersion5",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Book.WriteVersionSave(tr.Ctx, initID, &dataset.Dataset{
+		Peername: tr.Username,
+		Name:     "world_bank_population",
+		Commit: &dataset.Commit{
+			Timestamp: time.Date(2000, time.January, 6, 0, 0, 0, 0, time.UTC),
+			Title:     "v6",
+		},
+		Path:         "QmHashOfVersion6",
+		PreviousPath: "QmHashOfVersion5",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Book.PushDataset(tr.Ctx, diverged, tr.WorldBankRef()); !errors.Is(err, logbook.ErrMergeConflict) {
+		t.Errorf("expected pushing a diverged history to return ErrMergeConflict, got: %v", err)
+	}
+}
+
+func TestMergeLogPartialFailure(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	foreign := tr.foreignLogbook(t, "peer")
+	initIDs := map[string]string{}
+	for _, name := range []string{"valid_one", "broken", "valid_two"} {
+		initID, err := foreign.WriteDatasetInit(tr.Ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := foreign.WriteVersionSave(tr.Ctx, initID, &dataset.Dataset{
+				Peername: "peer",
+				Name:     name,
+				Commit:   &dataset.Commit{Title: fmt.Sprintf("commit %d", i)},
+				Path:     fmt.Sprintf("/ipfs/Qm%s%d", name, i),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		initIDs[name] = initID
+	}
+
+	logs, err := foreign.ListAllLogs(tr.Ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg := logs[0].DeepCopy()
+	broken, err := lg.Log(initIDs["broken"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// corrupt the branch history so operation sequence numbers run backwards
+	branch := broken.Logs[0]
+	branch.Ops[2].Seq = branch.Ops[1].Seq
+	if err := foreign.SignLog(lg); err != nil {
+		t.Fatal(err)
+	}
+
+	err = tr.Book.MergeLog(tr.Ctx, foreign.Author(), lg)
+	mergeErr := &logbook.MergeError{}
+	if !errors.As(err, &mergeErr) {
+		t.Fatalf("expected a *logbook.MergeError, got: %v", err)
+	}
+	if len(mergeErr.Failures) != 1 {
+		t.Fatalf("expected 1 failure, got: %d", len(mergeErr.Failures))
+	}
+	if f := mergeErr.Failures[0]; f.InitID != initIDs["broken"] || f.Name != "broken" {
+		t.Errorf("expected failure for the broken dataset, got: %s (%s)", f.Name, f.InitID)
+	}
+	if !mergeErr.Failed(initIDs["broken"]) || mergeErr.Failed(initIDs["valid_one"]) {
+		t.Errorf("expected only the broken dataset to be reported as failed")
+	}
+
+	for _, name := range []string{"valid_one", "valid_two"} {
+		items, err := tr.Book.Items(tr.Ctx, dsref.Ref{Username: "peer", Name: name}, 0, -1)
+		if err != nil {
+			t.Errorf("expected valid dataset %q to merge: %s", name, err)
+		} else if len(items) != 2 {
+			t.Errorf("expected valid dataset %q to have 2 versions, got: %d", name, len(items))
+		}
+	}
+	if _, err := tr.Book.RefToInitID(dsref.Ref{Username: "peer", Name: "broken"}); !errors.Is(err, logbook.ErrNotFound) {
+		t.Errorf("expected broken dataset not to merge, got: %v", err)
+	}
+}
+
+func TestSubscribeHeadCache(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	cache := dsref.NewCacheResolver(tr.Book)
+	logbook.SubscribeHeadCache(tr.bus, cache)
+
+	initID := tr.WriteWorldBankExample(t)
+	latest := func() dsref.Ref {
+		ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population"}
+		if _, err := cache.ResolveRef(tr.Ctx, &ref); err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+
+	if got := latest(); got.Path != "QmHashOfVersion3" {
+		t.Errorf("expected latest path to be %q, got: %q", "QmHashOfVersion3", got.Path)
+	}
+
+	// advancing the head invalidates the cached head
+	tr.WriteMoreWorldBankCommits(t, initID)
+	if got := latest(); got.Path != "QmHashOfVersion5" {
+		t.Errorf("expected latest path to reflect new head %q, got: %q", "QmHashOfVersion5", got.Path)
+	}
+
+	// relative version aliases follow the head
+	prev := dsref.Ref{Username: tr.Username, Name: "world_bank_population", Path: "HEAD~1"}
+	if _, err := cache.ResolveRef(tr.Ctx, &prev); err != nil {
+		t.Fatal(err)
+	}
+	if prev.Path != "QmHashOfVersion4" {
+		t.Errorf("expected HEAD~1 path to be %q, got: %q", "QmHashOfVersion4", prev.Path)
+	}
+	if err := tr.Book.WriteVersionSave(tr.Ctx, initID, &dataset.Dataset{
+		Peername: tr.Username,
+		Name:     "world_bank_population",
+		Commit: &dataset.Commit{
+			Timestamp: time.Date(2000, time.January, 6, 0, 0, 0, 0, time.UTC),
+			Title:     "v6",
+		},
+		Path:         "QmHashOfVersion6",
+		PreviousPath: "QmHashOfVersion5",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	prev = dsref.Ref{Username: tr.Username, Name: "world_bank_population", Path: "HEAD~1"}
+	if _, err := cache.ResolveRef(tr.Ctx, &prev); err != nil {
+		t.Fatal(err)
+	}
+	if prev.Path != "QmHashOfVersion5" {
+		t.Errorf("expected HEAD~1 path to follow new head %q, got: %q", "QmHashOfVersion5", prev.Path)
+	}
+
+	// renaming drops cached names
+	if err := tr.Book.WriteDatasetRename(tr.Ctx, initID, "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population"}
+	if _, err := cache.ResolveRef(tr.Ctx, &ref); !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Errorf("expected renamed dataset's old name not to resolve, got: %v", err)
+	}
+}
+
+// failingFS wraps a filesystem, failing all writes once fail is set
+type failingFS struct {
+	qfs.Filesystem
+	fail bool
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (fs *failingFS) Put(ctx context.Context, f qfs.File) (string, error) {
+	if fs.fail {
+		return "", errDiskFull
+	}
+	return fs.Filesystem.Put(ctx, f)
+}
+
+func TestWriteErrorHook(t *testing.T) {
+	ctx := context.Background()
+	fs := &failingFS{Filesystem: qfs.NewMemFS()}
+	book, err := logbook.NewJournal(testPrivKey(t), "test_author", event.NilBus, fs, "/mem/logbook.qfb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hookErrs []*logbook.WriteError
+	book.SetWriteErrorHook(func(_ context.Context, err *logbook.WriteError) {
+		hookErrs = append(hookErrs, err)
+	})
+
+	if _, err := book.WriteDatasetInit(ctx, "healthy"); err != nil {
+		t.Fatal(err)
+	}
+	if len(hookErrs) != 0 {
+		t.Fatalf("expected successful writes not to call the hook, got: %v", hookErrs)
+	}
+
+	fs.fail = true
+	_, err = book.WriteDatasetInit(ctx, "unlucky")
+	if !errors.Is(err, errDiskFull) {
+		t.Errorf("expected write to return a wrap of the filesystem error, got: %v", err)
+	}
+	if len(hookErrs) != 1 {
+		t.Fatalf("expected hook to be called once, got: %d", len(hookErrs))
+	}
+	if !errors.Is(hookErrs[0], errDiskFull) {
+		t.Errorf("expected hook error to wrap the filesystem error, got: %v", hookErrs[0])
+	}
+	if hookErrs[0].Location == "" {
+		t.Errorf("expected hook error to include the write location")
+	}
+
+	// a failed write doesn't lose track of where the book is stored
+	fs.fail = false
+	if _, err := book.WriteDatasetInit(ctx, "recovered"); err != nil {
+		t.Fatal(err)
+	}
+	if len(hookErrs) != 1 {
+		t.Errorf("expected hook not to be called after recovery, got: %d calls", len(hookErrs))
+	}
+}
+
+func TestDatasetSoftDelete(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	ctx := tr.Ctx
+	initID := tr.WriteWorldBankExample(t)
+	ref := dsref.Ref{Username: tr.Username, Name: "world_bank_population"}
+
+	if err := tr.Book.WriteDatasetUndelete(ctx, initID); err == nil {
+		t.Errorf("expected undeleting a dataset that isn't deleted to fail")
+	}
+	before, err := tr.Book.Items(ctx, ref, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.Book.WriteDatasetSoftDelete(ctx, initID); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Book.WriteDatasetSoftDelete(ctx, initID); !errors.Is(err, logbook.ErrDatasetDeleted) {
+		t.Errorf("expected deleting a deleted dataset to return ErrDatasetDeleted, got: %v", err)
+	}
+
+	got := ref.Copy()
+	if _, err := tr.Book.ResolveRef(ctx, &got); !errors.Is(err, logbook.ErrDatasetDeleted) {
+		t.Errorf("expected resolving a deleted dataset to return ErrDatasetDeleted, got: %v", err)
+	}
+
+	// the include-deleted flag resolves soft-deleted datasets
+	got = ref.Copy()
+	if _, err := tr.Book.ResolveRef(logbook.WithDeleted(ctx), &got); err != nil {
+		t.Fatalf("expected resolving a deleted dataset with WithDeleted to succeed, got: %s", err)
+	}
+	if got.InitID != initID || got.Path != "QmHashOfVersion3" {
+		t.Errorf("expected deleted dataset to resolve to its head. got: %s", got)
+	}
+
+	// history is preserved
+	after, err := tr.Book.Items(ctx, ref, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(before, after); diff != "" {
+		t.Errorf("expected deleted dataset to keep its history (-want +got):\n%s", diff)
+	}
+
+	if err := tr.Book.WriteDatasetUndelete(ctx, initID); err != nil {
+		t.Fatal(err)
+	}
+	got = ref.Copy()
+	if _, err := tr.Book.ResolveRef(ctx, &got); err != nil {
+		t.Fatalf("expected undeleted dataset to resolve, got: %s", err)
+	}
+	if got.Path != "QmHashOfVersion3" {
+		t.Errorf("expected undeleted dataset to resolve to its head, got: %s", got)
+	}
+	lg, err := tr.Book.Log(ctx, initID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lg.Name() != "world_bank_population" {
+		t.Errorf("expected delete & undelete to keep the dataset name, got: %q", lg.Name())
+	}
+}
+
 func TestMergeWithDivergentLogbookAuthorID(t *testing.T) {
 	tr, cleanup := newTestRunner(t)
 	defer cleanup()
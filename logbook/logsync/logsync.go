@@ -4,6 +4,7 @@ package logsync
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -238,7 +239,13 @@ func (lsync *Logsync) put(ctx context.Context, author identity.Author, ref dsref
 	}
 
 	if err := lsync.book.MergeLog(ctx, author, lg); err != nil {
-		return err
+		// other dataset logs failing to merge doesn't fail the push, so long as
+		// the pushed dataset merged
+		mergeErr := &logbook.MergeError{}
+		if !errors.As(err, &mergeErr) || mergeErr.Failed(lg.Logs[0].ID()) {
+			return err
+		}
+		log.Debugf("logsync.put merging logs: %s", err)
 	}
 
 	if lsync.pushed != nil {
@@ -379,7 +386,9 @@ type Pull struct {
 	Merge bool
 }
 
-// Do executes the pull
+// Do executes the pull. If merging is enabled and some dataset logs fail to
+// merge, Do returns the pulled log along with a *logbook.MergeError listing
+// the failures. Dataset logs not listed in the error were merged
 func (p *Pull) Do(ctx context.Context) (*oplog.Log, error) {
 	log.Debugf("pull.Do ref=%q", p.ref)
 	sender, r, err := p.remote.get(ctx, p.book.Author(), p.ref)
@@ -398,6 +407,10 @@ func (p *Pull) Do(ctx context.Context) (*oplog.Log, error) {
 
 	if p.Merge {
 		if err := p.book.MergeLog(ctx, sender, l); err != nil {
+			mergeErr := &logbook.MergeError{}
+			if errors.As(err, &mergeErr) {
+				return l, mergeErr
+			}
 			return nil, err
 		}
 	}
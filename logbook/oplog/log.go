@@ -16,7 +16,9 @@ import (
 	"crypto/cipher"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base32"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -477,9 +479,25 @@ func (lg *Log) Append(op Op) {
 			lg.authorID = op.AuthorID
 		}
 	}
+	if op.Seq == 0 {
+		op.Seq = lg.nextSeq()
+	}
 	lg.Ops = append(lg.Ops, op)
 }
 
+// nextSeq returns the sequence number for the next operation appended to the
+// log. Logs with unknown sequence numbers continue to have unknown sequences
+func (lg *Log) nextSeq() uint64 {
+	if len(lg.Ops) == 0 {
+		return 0
+	}
+	prev := lg.Ops[len(lg.Ops)-1].Seq
+	if prev == 0 && len(lg.Ops) > 1 {
+		return 0
+	}
+	return prev + 1
+}
+
 // ID returns the hash of the initialization operation
 // if the log is empty, returns the empty string
 func (lg Log) ID() string {
@@ -656,6 +674,21 @@ func (lg Log) Verify(pub crypto.PubKey) error {
 	if !ok {
 		return fmt.Errorf("invalid signature")
 	}
+	return lg.VerifySeq()
+}
+
+// VerifySeq confirms known operation sequence numbers increase monotonically
+func (lg Log) VerifySeq() error {
+	var prev uint64
+	for i, op := range lg.Ops {
+		if i == 0 || op.Seq == 0 {
+			continue
+		}
+		if op.Seq <= prev {
+			return fmt.Errorf("operation %d has sequence %d, which doesn't follow sequence %d", i, op.Seq, prev)
+		}
+		prev = op.Seq
+	}
 	return nil
 }
 
@@ -681,6 +714,36 @@ func (lg Log) SigningBytes() []byte {
 	return hasher.Sum(nil)
 }
 
+// TreeSigningBytes prepares a byte slice for signing from every field of
+// every operation in a log and its descendants. Unlike SigningBytes, changing
+// any part of any operation in the hierarchy changes the result
+func (lg Log) TreeSigningBytes() []byte {
+	hasher := sha256.New()
+	lg.writeTree(hasher)
+	return hasher.Sum(nil)
+}
+
+// writeTree writes the flatbuffer encoding of each operation in a log
+// hierarchy to w, prefixing each run of operations, logs & encoded operation
+// with its length so no two hierarchies write the same bytes
+func (lg Log) writeTree(w io.Writer) {
+	writeLen := func(n int) {
+		binary.Write(w, binary.BigEndian, uint64(n))
+	}
+	writeLen(len(lg.Ops))
+	for _, op := range lg.Ops {
+		builder := flatbuffers.NewBuilder(0)
+		builder.Finish(op.MarshalFlatbuffer(builder))
+		data := builder.FinishedBytes()
+		writeLen(len(data))
+		w.Write(data)
+	}
+	writeLen(len(lg.Logs))
+	for _, l := range lg.Logs {
+		l.writeTree(w)
+	}
+}
+
 // FlatbufferBytes marshals a log to flabuffer-formatted bytes
 func (lg Log) FlatbufferBytes() []byte {
 	builder := flatbuffers.NewBuilder(0)
@@ -801,6 +864,13 @@ type Op struct {
 	Timestamp int64  // operation timestamp, for annotation purposes only
 	Size      int64  // size of the referenced value in bytes
 	Note      string // operation annotation for users. eg: commit title
+
+	// Seq is a logical sequence number, ordering operations within a log
+	// independent of wall-clock time. The first operation in a log has a
+	// sequence of 0, each subsequent operation increments the sequence of the
+	// operation before it. Operations written before sequence numbers were
+	// introduced have a sequence of 0, which is treated as unknown
+	Seq uint64
 }
 
 // Equal tests equality between two operations
@@ -814,7 +884,8 @@ func (o Op) Equal(b Op) bool {
 		o.AuthorID == b.AuthorID &&
 		o.Timestamp == b.Timestamp &&
 		o.Size == b.Size &&
-		o.Note == b.Note
+		o.Note == b.Note &&
+		o.Seq == b.Seq
 }
 
 // Hash uses lower-case base32 encoding for id bytes for a few reasons:
@@ -870,6 +941,7 @@ func (o Op) MarshalFlatbuffer(builder *flatbuffers.Builder) flatbuffers.UOffsetT
 	logfb.OperationAddTimestamp(builder, o.Timestamp)
 	logfb.OperationAddSize(builder, o.Size)
 	logfb.OperationAddNote(builder, note)
+	logfb.OperationAddSeq(builder, o.Seq)
 	return logfb.OperationEnd(builder)
 }
 
@@ -885,6 +957,7 @@ func UnmarshalOpFlatbuffer(o *logfb.Operation) Op {
 		AuthorID:  string(o.AuthorID()),
 		Size:      o.Size(),
 		Note:      string(o.Note()),
+		Seq:       o.Seq(),
 	}
 
 	if o.RelationsLength() > 0 {
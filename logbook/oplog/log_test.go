@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -208,6 +209,86 @@ func TestJournalSignLog(t *testing.T) {
 	}
 }
 
+func TestLogTreeSigningBytes(t *testing.T) {
+	newTree := func() *Log {
+		lg := InitLog(Op{Type: OpTypeInit, Model: 0x1, Name: "apples"})
+		child := InitLog(Op{Type: OpTypeInit, Model: 0x2, Name: "oranges"})
+		child.Append(Op{Type: OpTypeAmend, Model: 0x2, Ref: "ref_0", Note: "first", Timestamp: 1})
+		lg.AddChild(child)
+		return lg
+	}
+
+	orig := newTree()
+	received, err := FromFlatbufferBytes(orig.FlatbufferBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(orig.TreeSigningBytes(), received.TreeSigningBytes()) {
+		t.Errorf("expected tree signing bytes to survive a flatbuffer round trip")
+	}
+
+	edits := map[string]func(op *Op){
+		"note":      func(op *Op) { op.Note = "changed" },
+		"timestamp": func(op *Op) { op.Timestamp = 2 },
+		"seq":       func(op *Op) { op.Seq = 5 },
+		"relations": func(op *Op) { op.Relations = []string{"other"} },
+	}
+	for name, edit := range edits {
+		lg := newTree()
+		edit(&lg.Logs[0].Ops[1])
+		if bytes.Equal(orig.TreeSigningBytes(), lg.TreeSigningBytes()) {
+			t.Errorf("%s: expected edit to change tree signing bytes", name)
+		}
+	}
+}
+
+func TestLogSeq(t *testing.T) {
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	lg := InitLog(Op{Type: OpTypeInit, Model: 0x1, Name: "apples"})
+	for i := 0; i < 3; i++ {
+		lg.Append(Op{Type: OpTypeAmend, Model: 0x1, Ref: fmt.Sprintf("ref_%d", i)})
+	}
+	for i, op := range lg.Ops {
+		if op.Seq != uint64(i) {
+			t.Errorf("op %d sequence mismatch. expected: %d, got: %d", i, i, op.Seq)
+		}
+	}
+
+	pk := tr.PrivKey
+	if err := lg.Sign(pk); err != nil {
+		t.Fatal(err)
+	}
+	received, err := FromFlatbufferBytes(lg.FlatbufferBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := received.Verify(pk.GetPublic()); err != nil {
+		t.Errorf("expected ordered sequence to verify, got: %s", err)
+	}
+
+	// sequences that don't increase fail verification
+	received.Ops[2].Seq = 1
+	if err := received.Verify(pk.GetPublic()); err == nil {
+		t.Errorf("expected out-of-order sequence to fail verification")
+	}
+
+	// logs written before sequence numbers are sequence-unknown, and continue
+	// to be after appending
+	legacy := &Log{Ops: []Op{{Type: OpTypeInit, Model: 0x1}, {Type: OpTypeAmend, Model: 0x1}}}
+	legacy.Append(Op{Type: OpTypeAmend, Model: 0x1})
+	if legacy.Ops[2].Seq != 0 {
+		t.Errorf("expected op appended to legacy log to have unknown sequence, got: %d", legacy.Ops[2].Seq)
+	}
+	if err := legacy.Sign(pk); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Verify(pk.GetPublic()); err != nil {
+		t.Errorf("expected legacy log to verify, got: %s", err)
+	}
+}
+
 func TestLogHead(t *testing.T) {
 	l := &Log{}
 	if !l.Head().Equal(Op{}) {
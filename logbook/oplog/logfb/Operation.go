@@ -131,8 +131,20 @@ func (rcv *Operation) Note() []byte {
 	return nil
 }
 
+func (rcv *Operation) Seq() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(24))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Operation) MutateSeq(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(24, n)
+}
+
 func OperationStart(builder *flatbuffers.Builder) {
-	builder.StartObject(10)
+	builder.StartObject(11)
 }
 func OperationAddType(builder *flatbuffers.Builder, type_ OpType) {
 	builder.PrependInt8Slot(0, int8(type_), 0)
@@ -167,6 +179,9 @@ func OperationAddSize(builder *flatbuffers.Builder, size int64) {
 func OperationAddNote(builder *flatbuffers.Builder, note flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(9, flatbuffers.UOffsetT(note), 0)
 }
+func OperationAddSeq(builder *flatbuffers.Builder, seq uint64) {
+	builder.PrependUint64Slot(10, seq, 0)
+}
 func OperationEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -2,6 +2,7 @@ package logbook
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -68,7 +69,14 @@ func (b *BookBuilder) DatasetDelete(ctx context.Context, t *testing.T, initID st
 func (b *BookBuilder) AddForeign(ctx context.Context, t *testing.T, log *oplog.Log) {
 	log.Sign(b.Book.pk)
 	if err := b.Book.MergeLog(ctx, b.Book.Author(), log); err != nil {
-		t.Fatal(err)
+		mergeErr := &MergeError{}
+		if !errors.As(err, &mergeErr) {
+			t.Fatal(err)
+		}
+		// datasets that merged are kept, report each one that didn't
+		for _, f := range mergeErr.Failures {
+			t.Errorf("merging foreign dataset %q (%s): %s", f.Name, f.InitID, f.Err)
+		}
 	}
 }
 
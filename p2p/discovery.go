@@ -2,6 +2,7 @@ package p2p
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	peer "github.com/libp2p/go-libp2p-core/peer"
@@ -11,6 +12,9 @@ import (
 const (
 	discoveryConnTimeout = time.Second * 30
 	discoveryInterval    = time.Second * 5
+	// discoveryQueryTimeout is the length of time on-demand discovery listens
+	// for local peers
+	discoveryQueryTimeout = time.Second * 2
 )
 
 // setupDiscovery initiates local peer discovery, allocating a discovery service
@@ -33,3 +37,64 @@ func (n *QriNode) HandlePeerFound(pinfo peer.AddrInfo) {
 	defer cancel()
 	n.Host().Connect(ctx, pinfo)
 }
+
+// discoverLocalPeers runs a one-off mDNS query, returning local peers that
+// respond before the query times out
+func (n *QriNode) discoverLocalPeers(ctx context.Context) ([]peer.AddrInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryQueryTimeout)
+	defer cancel()
+
+	svc, err := discovery.NewMdnsService(ctx, n.host, discoveryQueryTimeout, discovery.ServiceTag)
+	if err != nil {
+		return nil, err
+	}
+	found := &peerCollector{}
+	svc.RegisterNotifee(found)
+	<-ctx.Done()
+	if err := svc.Close(); err != nil {
+		log.Debugf("closing on-demand discovery service: %s", err)
+	}
+	return found.found(), nil
+}
+
+// peerCollector is a discovery notifee that gathers found peers
+type peerCollector struct {
+	lk    sync.Mutex
+	peers []peer.AddrInfo
+}
+
+// HandlePeerFound implements the discovery.Notifee interface
+func (pc *peerCollector) HandlePeerFound(pinfo peer.AddrInfo) {
+	pc.lk.Lock()
+	defer pc.lk.Unlock()
+	pc.peers = append(pc.peers, pinfo)
+}
+
+func (pc *peerCollector) found() []peer.AddrInfo {
+	pc.lk.Lock()
+	defer pc.lk.Unlock()
+	return pc.peers
+}
+
+// connectQriPeers connects to a set of peers, waiting for the qri profile
+// exchange to complete with those that support qri
+func (n *QriNode) connectQriPeers(ctx context.Context, pinfos []peer.AddrInfo) {
+	wg := sync.WaitGroup{}
+	for _, pinfo := range pinfos {
+		if pinfo.ID == n.host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func(pinfo peer.AddrInfo) {
+			defer wg.Done()
+			if err := n.host.Connect(ctx, pinfo); err != nil {
+				log.Debugf("connecting to discovered peer %q: %s", pinfo.ID, err)
+				return
+			}
+			if err := n.qis.QriProfileRequest(ctx, pinfo.ID); err != nil {
+				log.Debugf("upgrading discovered peer %q: %s", pinfo.ID, err)
+			}
+		}(pinfo)
+	}
+	wg.Wait()
+}
@@ -62,6 +62,23 @@ type QriNode struct {
 
 	// localResolver allows the node to resolve local dataset references
 	localResolver dsref.Resolver
+	// reaper tracks connected peers that have stopped responding
+	reaper *peerReaper
+	// filters holds filters of the datasets connected peers hold
+	filters *peerFilters
+	// stats tracks each peer's reference resolution success rate
+	stats *peerStats
+	// findProviders returns peers likely to hold a reference, overridden in
+	// tests
+	findProviders func(ctx context.Context, ref dsref.Ref) ([]peer.AddrInfo, error)
+	// serveCache caches answers to peer resolution requests. nil disables
+	// caching
+	serveCache *dsref.CacheResolver
+	// served counts resolved peer requests by requested reference
+	served *servedRefStats
+	// resolveSem limits the number of inbound resolution requests handled at
+	// once
+	resolveSem chan struct{}
 
 	// msgState keeps a "scratch pad" of message IDS & timeouts
 	msgState *sync.Map
@@ -123,6 +140,16 @@ func NewQriNode(r repo.Repo, p2pconf *config.P2P, pub event.Publisher, localReso
 	}
 
 	node.qis = NewQriProfileService(node.Repo, node.pub)
+	node.reaper = newPeerReaper(node.pingPeer)
+	node.filters = newPeerFilters()
+	node.stats = newPeerStats()
+	node.served = newServedRefStats(servedRefStatsCapacity)
+	node.findProviders = node.findRefProviders
+	maxHandlers := p2pconf.MaxResolveHandlers
+	if maxHandlers <= 0 {
+		maxHandlers = DefaultMaxResolveHandlers
+	}
+	node.resolveSem = make(chan struct{}, maxHandlers)
 	return node, nil
 }
 
@@ -201,6 +228,7 @@ func (n *QriNode) GoOnline(c context.Context) (err error) {
 
 	// add ref resolution capabilities:
 	n.host.SetStreamHandler(ResolveRefProtocolID, n.resolveRefHandler)
+	n.host.SetStreamHandler(InitIDFilterProtocolID, n.initIDFilterHandler)
 
 	// register ourselves as a notifee on connected
 	n.host.Network().Notify(n.notifee)
@@ -241,6 +269,10 @@ func (n *QriNode) startOnlineServices(ctx context.Context) error {
 	go n.Bootstrap(n.cfg.QriBootstrapAddrs)
 	// Bootstrap to IPFS network if this node is using an IPFS fs
 	go n.BootstrapIPFS()
+	// periodically prune unresponsive peers from reference resolution
+	go n.runPeerReaper(ctx, peerLivenessInterval)
+	// periodically exchange dataset filters to prune reference resolution
+	go n.runInitIDFilterExchange(ctx, initIDFilterExchangeInterval)
 	return nil
 }
 
@@ -369,6 +401,10 @@ func (n *QriNode) disconnected(_ net.Network, conn net.Conn) {
 	n.pub.Publish(context.Background(), event.ETP2PPeerDisconnected, pi)
 
 	n.qis.HandleQriPeerDisconnect(pi.ID)
+	if n.host.Network().Connectedness(pi.ID) != net.Connected {
+		n.filters.remove(pi.ID)
+		n.stats.forget(pi.ID)
+	}
 }
 
 func (n *QriNode) libp2pSubscribe(ctx context.Context) error {
@@ -0,0 +1,284 @@
+package p2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/helpers"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/qri-io/qri/dsref"
+)
+
+const (
+	// InitIDFilterProtocolID is the protocol on which qri nodes exchange
+	// filters of the datasets they hold
+	InitIDFilterProtocolID = protocol.ID("/qri/initidfilter/0.1.0")
+	// initIDFilterExchangeInterval is how often filters are requested from
+	// connected qri peers
+	initIDFilterExchangeInterval = time.Minute * 5
+	// initIDFilterBitsPerKey and initIDFilterHashes give a false positive rate
+	// of roughly 1%
+	initIDFilterBitsPerKey = 10
+	initIDFilterHashes     = 7
+	// initIDFilterMinBits is the smallest filter size
+	initIDFilterMinBits = 64
+	// initIDFilterMaxHashes & initIDFilterMaxBytes bound the filters accepted
+	// from peers. 1MiB of bits holds roughly 800,000 keys
+	initIDFilterMaxHashes = 16
+	initIDFilterMaxBytes  = 1 << 20
+	// initIDFilterMaxMessageSize bounds the encoded size of a filter read from
+	// a peer, allowing for base64 expansion of the filter bits
+	initIDFilterMaxMessageSize = initIDFilterMaxBytes * 2
+)
+
+// initIDFilter is a bloom filter of the dataset init IDs & human-friendly
+// names a peer holds. Filters can return false positives, but never false
+// negatives
+type initIDFilter struct {
+	Bits []byte `json:"bits"`
+	K    uint32 `json:"k"`
+}
+
+// newInitIDFilter creates a filter sized for n keys
+func newInitIDFilter(n int) *initIDFilter {
+	m := n * initIDFilterBitsPerKey
+	if m < initIDFilterMinBits {
+		m = initIDFilterMinBits
+	}
+	return &initIDFilter{
+		Bits: make([]byte, (m+7)/8),
+		K:    initIDFilterHashes,
+	}
+}
+
+// validate checks a filter received from a peer is usable and within size
+// limits
+func (f *initIDFilter) validate() error {
+	if f.K == 0 || f.K > initIDFilterMaxHashes {
+		return fmt.Errorf("filter hash count %d out of range 1-%d", f.K, initIDFilterMaxHashes)
+	}
+	if len(f.Bits) == 0 || len(f.Bits) > initIDFilterMaxBytes {
+		return fmt.Errorf("filter size %d bytes out of range 1-%d", len(f.Bits), initIDFilterMaxBytes)
+	}
+	return nil
+}
+
+func (f *initIDFilter) add(key string) {
+	for _, loc := range f.locations(key) {
+		f.Bits[loc/8] |= 1 << (loc % 8)
+	}
+}
+
+// mayContain reports false if key is definitely not in the filter
+func (f *initIDFilter) mayContain(key string) bool {
+	if len(f.Bits) == 0 {
+		return true
+	}
+	for _, loc := range f.locations(key) {
+		if f.Bits[loc/8]&(1<<(loc%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// locations uses double hashing to derive K bit positions for a key
+func (f *initIDFilter) locations(key string) []uint64 {
+	sum := sha256.Sum256([]byte(key))
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16])
+	m := uint64(len(f.Bits) * 8)
+	locs := make([]uint64, f.K)
+	for i := range locs {
+		locs[i] = (h1 + uint64(i)*h2) % m
+	}
+	return locs
+}
+
+// mayResolve reports false if the filter shows a reference is definitely not
+// held. References with neither an InitID or a human-friendly name may
+// resolve
+func (f *initIDFilter) mayResolve(ref dsref.Ref) bool {
+	if ref.InitID != "" {
+		return f.mayContain(ref.InitID)
+	}
+	if ref.Username != "" && ref.Name != "" {
+		return f.mayContain(ref.Human())
+	}
+	return true
+}
+
+// peerFilters holds the most recent filter received from each peer
+type peerFilters struct {
+	lk      sync.Mutex
+	filters map[peer.ID]*initIDFilter
+}
+
+func newPeerFilters() *peerFilters {
+	return &peerFilters{filters: map[peer.ID]*initIDFilter{}}
+}
+
+func (pf *peerFilters) set(pid peer.ID, f *initIDFilter) {
+	pf.lk.Lock()
+	defer pf.lk.Unlock()
+	pf.filters[pid] = f
+}
+
+// remove drops the filter for a peer
+func (pf *peerFilters) remove(pid peer.ID) {
+	if pf == nil {
+		return
+	}
+	pf.lk.Lock()
+	defer pf.lk.Unlock()
+	delete(pf.filters, pid)
+}
+
+// prune removes peers with a filter indicating they don't hold a reference.
+// Peers that haven't sent a filter are kept. Filters are only refreshed
+// periodically, so pruned peers may have since gained the reference
+func (pf *peerFilters) prune(pids []peer.ID, ref dsref.Ref) []peer.ID {
+	if pf == nil {
+		return pids
+	}
+	pf.lk.Lock()
+	defer pf.lk.Unlock()
+	pruned := make([]peer.ID, 0, len(pids))
+	for _, pid := range pids {
+		if f, ok := pf.filters[pid]; ok && !f.mayResolve(ref) {
+			continue
+		}
+		pruned = append(pruned, pid)
+	}
+	return pruned
+}
+
+// hinted returns peers with a filter indicating they may hold a reference.
+// References with neither an InitID or a human-friendly name match no peers
+func (pf *peerFilters) hinted(ref dsref.Ref) []peer.ID {
+	if pf == nil || (ref.InitID == "" && (ref.Username == "" || ref.Name == "")) {
+		return nil
+	}
+	pf.lk.Lock()
+	defer pf.lk.Unlock()
+	var pids []peer.ID
+	for pid, f := range pf.filters {
+		if f.mayResolve(ref) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// localInitIDFilter builds a filter of the datasets in this node's logbook
+func (n *QriNode) localInitIDFilter(ctx context.Context) (*initIDFilter, error) {
+	book := n.Repo.Logbook()
+	if book == nil {
+		return newInitIDFilter(0), nil
+	}
+	logs, err := book.ListAllLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, userLog := range logs {
+		for _, dsLog := range userLog.Logs {
+			keys = append(keys, dsLog.ID(), fmt.Sprintf("%s/%s", userLog.Name(), dsLog.Name()))
+		}
+	}
+
+	f := newInitIDFilter(len(keys))
+	for _, key := range keys {
+		f.add(key)
+	}
+	return f, nil
+}
+
+// ExchangeInitIDFilters requests a filter of held datasets from each live
+// connected qri peer. Reference resolution skips peers with a filter showing
+// they don't hold the requested reference
+func (n *QriNode) ExchangeInitIDFilters(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pid := range n.LiveQriPeerIDs() {
+		wg.Add(1)
+		go func(pid peer.ID) {
+			defer wg.Done()
+			f, err := n.requestInitIDFilter(ctx, pid)
+			if err != nil {
+				log.Debugf("requesting init ID filter from %q: %s", pid, err)
+				return
+			}
+			n.filters.set(pid, f)
+		}(pid)
+	}
+	wg.Wait()
+}
+
+// runInitIDFilterExchange calls ExchangeInitIDFilters every interval until
+// the context is cancelled
+func (n *QriNode) runInitIDFilterExchange(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			n.ExchangeInitIDFilters(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *QriNode) requestInitIDFilter(ctx context.Context, pid peer.ID) (*initIDFilter, error) {
+	ctx, cancel := context.WithTimeout(ctx, p2pRefResolverTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, pid, InitIDFilterProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		go helpers.FullClose(s)
+	}()
+
+	f := &initIDFilter{}
+	if err := json.NewDecoder(io.LimitReader(s, initIDFilterMaxMessageSize)).Decode(f); err != nil {
+		return nil, fmt.Errorf("error decoding init ID filter: %s", err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("invalid init ID filter: %w", err)
+	}
+	return f, nil
+}
+
+// initIDFilterHandler responds to requests on the InitIDFilterProtocolID with
+// a filter of this node's datasets
+func (n *QriNode) initIDFilterHandler(s network.Stream) {
+	defer helpers.FullClose(s)
+	ctx, cancel := context.WithTimeout(context.Background(), p2pRefResolverTimeout)
+	defer cancel()
+
+	f, err := n.localInitIDFilter(ctx)
+	if err != nil {
+		log.Debugf("p2p.initIDFilterHandler - error building filter: %s", err)
+		return
+	}
+
+	ws := WrapStream(s)
+	if err := ws.enc.Encode(f); err != nil {
+		log.Debugf("p2p.initIDFilterHandler - error encoding filter: %s", err)
+		return
+	}
+	if err := ws.w.Flush(); err != nil {
+		log.Debugf("p2p.initIDFilterHandler - error flushing stream: %s", err)
+	}
+}
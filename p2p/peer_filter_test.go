@@ -0,0 +1,165 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/event"
+	p2ptest "github.com/qri-io/qri/p2p/test"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+)
+
+func TestInitIDFilter(t *testing.T) {
+	f := newInitIDFilter(100)
+	for i := 0; i < 100; i++ {
+		f.add(fmt.Sprintf("init_id_%d", i))
+	}
+	for i := 0; i < 100; i++ {
+		if key := fmt.Sprintf("init_id_%d", i); !f.mayContain(key) {
+			t.Errorf("expected filter to contain %q", key)
+		}
+	}
+	if f.mayContain("definitely_not_an_init_id") {
+		t.Errorf("expected filter to exclude missing key")
+	}
+
+	// an empty filter excludes nothing
+	if !(&initIDFilter{}).mayResolve(dsref.Ref{InitID: "init_id"}) {
+		t.Errorf("expected empty filter to permit resolution")
+	}
+
+	if err := f.validate(); err != nil {
+		t.Errorf("expected filter to be valid, got: %s", err)
+	}
+	bad := []*initIDFilter{
+		{Bits: f.Bits, K: 0},
+		{Bits: f.Bits, K: initIDFilterMaxHashes + 1},
+		{Bits: nil, K: initIDFilterHashes},
+		{Bits: make([]byte, initIDFilterMaxBytes+1), K: initIDFilterHashes},
+	}
+	for i, b := range bad {
+		if err := b.validate(); err == nil {
+			t.Errorf("case %d: expected filter with k=%d & %d bytes to be invalid", i, b.K, len(b.Bits))
+		}
+	}
+}
+
+func TestResolveRefPrunesFilteredPeers(t *testing.T) {
+	ctx := context.Background()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, 1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, nil)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	// the local filter advertises datasets in the logbook
+	local, err := n.localInitIDFilter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !local.mayResolve(dsref.Ref{Username: "test-repo-0", Name: "cities"}) {
+		t.Errorf("expected local filter to advertise test-repo-0/cities")
+	}
+
+	has := cfgtest.GetTestPeerInfo(1).PeerID
+	unknown := cfgtest.GetTestPeerInfo(2).PeerID
+	lacks := cfgtest.GetTestPeerInfo(3).PeerID
+	for _, pid := range []peer.ID{has, unknown, lacks} {
+		markQriPeer(t, n, pid)
+	}
+
+	hasFilter := newInitIDFilter(1)
+	hasFilter.add("init_id")
+	n.filters.set(has, hasFilter)
+	lacksFilter := newInitIDFilter(1)
+	lacksFilter.add("another_init_id")
+	n.filters.set(lacks, lacksFilter)
+
+	rr := n.NewP2PRefResolver().(*p2pRefResolver)
+	got := map[peer.ID]bool{}
+	for _, pid := range rr.fanOutPeerIDs(dsref.Ref{InitID: "init_id"}) {
+		got[pid] = true
+	}
+	if !got[has] || !got[unknown] {
+		t.Errorf("expected fan-out to include advertising peer and peer without a filter, got: %v", got)
+	}
+	if got[lacks] {
+		t.Errorf("expected fan-out to exclude peer whose filter doesn't advertise the ref")
+	}
+
+	// references without an init ID or name can't be pruned
+	if pids := rr.fanOutPeerIDs(dsref.Ref{}); len(pids) != 3 {
+		t.Errorf("expected all peers for an empty reference, got: %v", pids)
+	}
+}
+
+func TestResolveRefSkipsFilteredPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 2)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+	connectTestPeer(ctx, t, nodes[0], nodes[1])
+	for i := 0; i < 40 && !hasPeerID(nodes[0].LiveQriPeerIDs(), nodes[1].host.ID()); i++ {
+		time.Sleep(time.Millisecond * 25)
+	}
+
+	// a filter that doesn't list the dataset
+	filter := newInitIDFilter(1)
+	filter.add("another_init_id")
+	nodes[0].filters.set(nodes[1].host.ID(), filter)
+
+	rr := nodes[0].NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.PeerSelector = TrustedPeers(nodes[1].host.ID())
+	}).(*p2pRefResolver)
+	attempts := &dsref.Attempts{}
+	ref := &dsref.Ref{Username: "test-repo-1", Name: "cities"}
+	if _, err := rr.ResolveRef(dsref.WithAttempts(ctx, attempts), ref); !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Fatalf("expected peer excluded by its filter not to be asked, got: %v", err)
+	}
+	got := attempts.List()
+	if len(got) != 1 || got[0].Stage != ResolveStageConnectedPeers || got[0].Requests != 0 {
+		t.Errorf("expected a single attempt that prunes the filtered peer, got: %v", got)
+	}
+
+	// disconnecting drops the peer's filter
+	hasFilter := func() bool {
+		pf := nodes[0].filters
+		pf.lk.Lock()
+		defer pf.lk.Unlock()
+		_, ok := pf.filters[nodes[1].host.ID()]
+		return ok
+	}
+	nodes[0].host.Network().ClosePeer(nodes[1].host.ID())
+	for i := 0; i < 40 && hasFilter(); i++ {
+		time.Sleep(time.Millisecond * 25)
+	}
+	if hasFilter() {
+		t.Errorf("expected filter to be removed when the peer disconnected")
+	}
+}
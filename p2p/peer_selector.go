@@ -0,0 +1,54 @@
+package p2p
+
+import (
+	"math/rand"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// PeerSelector chooses which peers to send reference resolution requests to.
+// It's given the connected qri peers eligible for a request, in preferred
+// order, and returns the ordered subset to query
+type PeerSelector func(peers []peer.ID) []peer.ID
+
+// AllPeers is a PeerSelector that queries every eligible peer
+func AllPeers(peers []peer.ID) []peer.ID {
+	return peers
+}
+
+// RandomPeers returns a PeerSelector that queries a random subset of at most
+// k peers
+func RandomPeers(k int) PeerSelector {
+	return func(peers []peer.ID) []peer.ID {
+		if k >= len(peers) {
+			return peers
+		}
+		if k <= 0 {
+			return nil
+		}
+		selected := make([]peer.ID, len(peers))
+		copy(selected, peers)
+		rand.Shuffle(len(selected), func(i, j int) {
+			selected[i], selected[j] = selected[j], selected[i]
+		})
+		return selected[:k]
+	}
+}
+
+// TrustedPeers returns a PeerSelector that only queries peers in the trusted
+// set, preserving the order peers are given in
+func TrustedPeers(trusted ...peer.ID) PeerSelector {
+	set := make(map[peer.ID]struct{}, len(trusted))
+	for _, pid := range trusted {
+		set[pid] = struct{}{}
+	}
+	return func(peers []peer.ID) []peer.ID {
+		selected := make([]peer.ID, 0, len(peers))
+		for _, pid := range peers {
+			if _, ok := set[pid]; ok {
+				selected = append(selected, pid)
+			}
+		}
+		return selected
+	}
+}
@@ -0,0 +1,72 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+)
+
+func TestPeerSelectors(t *testing.T) {
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, nil)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	connected := map[peer.ID]bool{}
+	for i := 1; i <= 5; i++ {
+		pid := cfgtest.GetTestPeerInfo(i).PeerID
+		markQriPeer(t, n, pid)
+		connected[pid] = true
+	}
+
+	fanOut := func(sel PeerSelector) []peer.ID {
+		rr := n.NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+			o.PeerSelector = sel
+		}).(*p2pRefResolver)
+		return rr.fanOutPeerIDs(dsref.Ref{Username: "peer", Name: "dataset"})
+	}
+
+	if got := fanOut(nil); len(got) != 5 {
+		t.Errorf("expected default selector to query all 5 peers, got: %d", len(got))
+	}
+	if got := fanOut(AllPeers); len(got) != 5 {
+		t.Errorf("expected AllPeers to query all 5 peers, got: %d", len(got))
+	}
+
+	const k = 2
+	for i := 0; i < 10; i++ {
+		got := fanOut(RandomPeers(k))
+		if len(got) != k {
+			t.Fatalf("expected RandomPeers(%d) to query %d peers, got: %d", k, k, len(got))
+		}
+		if got[0] == got[1] {
+			t.Errorf("expected RandomPeers to select distinct peers, got: %v", got)
+		}
+		for _, pid := range got {
+			if !connected[pid] {
+				t.Errorf("RandomPeers selected unconnected peer %s", pid)
+			}
+		}
+	}
+	if got := fanOut(RandomPeers(10)); len(got) != 5 {
+		t.Errorf("expected RandomPeers with k larger than the peer count to query all peers, got: %d", len(got))
+	}
+
+	trusted := cfgtest.GetTestPeerInfo(3).PeerID
+	notConnected := cfgtest.GetTestPeerInfo(7).PeerID
+	got := fanOut(TrustedPeers(trusted, notConnected))
+	if len(got) != 1 || got[0] != trusted {
+		t.Errorf("expected TrustedPeers to only query connected trusted peer %s, got: %v", trusted, got)
+	}
+}
@@ -0,0 +1,68 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// peerStats tracks how often each connected peer successfully resolves
+// references. Reference resolution queries historically successful peers
+// first. History is dropped when a peer disconnects
+type peerStats struct {
+	lk        sync.Mutex
+	attempts  map[peer.ID]int
+	successes map[peer.ID]int
+}
+
+func newPeerStats() *peerStats {
+	return &peerStats{
+		attempts:  map[peer.ID]int{},
+		successes: map[peer.ID]int{},
+	}
+}
+
+// record adds the outcome of a resolution request to a peer's history
+func (ps *peerStats) record(pid peer.ID, success bool) {
+	if ps == nil {
+		return
+	}
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	ps.attempts[pid]++
+	if success {
+		ps.successes[pid]++
+	}
+}
+
+// forget drops a peer's history
+func (ps *peerStats) forget(pid peer.ID) {
+	if ps == nil {
+		return
+	}
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	delete(ps.attempts, pid)
+	delete(ps.successes, pid)
+}
+
+// score is a peer's smoothed success rate. Peers with no history score 0.5,
+// ranking them between peers with good & poor track records
+func (ps *peerStats) score(pid peer.ID) float64 {
+	return float64(ps.successes[pid]+1) / float64(ps.attempts[pid]+2)
+}
+
+// order sorts peers by success rate, highest first. Peers with equal scores
+// keep their relative order
+func (ps *peerStats) order(pids []peer.ID) []peer.ID {
+	if ps == nil {
+		return pids
+	}
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	sort.SliceStable(pids, func(i, j int) bool {
+		return ps.score(pids[i]) > ps.score(pids[j])
+	})
+	return pids
+}
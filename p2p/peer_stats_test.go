@@ -0,0 +1,208 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+)
+
+func TestResolveRefOrdersPeersBySuccess(t *testing.T) {
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, nil)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	poor := cfgtest.GetTestPeerInfo(1).PeerID
+	unknown := cfgtest.GetTestPeerInfo(2).PeerID
+	successful := cfgtest.GetTestPeerInfo(3).PeerID
+	for _, pid := range []peer.ID{poor, unknown, successful} {
+		markQriPeer(t, n, pid)
+	}
+
+	for i := 0; i < 3; i++ {
+		n.stats.record(successful, true)
+		n.stats.record(poor, false)
+	}
+	// a single success doesn't outweigh a poor track record
+	n.stats.record(poor, true)
+
+	rr := n.NewP2PRefResolver().(*p2pRefResolver)
+	got := rr.fanOutPeerIDs(dsref.Ref{})
+	expect := []peer.ID{successful, unknown, poor}
+	if len(got) != len(expect) {
+		t.Fatalf("fan-out length mismatch. expected: %d, got: %d", len(expect), len(got))
+	}
+	for i, pid := range expect {
+		if got[i] != pid {
+			t.Errorf("fan-out order mismatch at index %d. expected: %s, got: %s", i, pid, got[i])
+		}
+	}
+}
+
+func TestResolveRefAsksPeersByRank(t *testing.T) {
+	ctx := context.Background()
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, nil)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	poor := cfgtest.GetTestPeerInfo(1).PeerID
+	unknown := cfgtest.GetTestPeerInfo(2).PeerID
+	successful := cfgtest.GetTestPeerInfo(3).PeerID
+	for _, pid := range []peer.ID{poor, unknown, successful} {
+		markQriPeer(t, n, pid)
+	}
+	for i := 0; i < 3; i++ {
+		n.stats.record(successful, true)
+		n.stats.record(poor, false)
+	}
+
+	var (
+		lk       sync.Mutex
+		asked    []peer.ID
+		inFlight int
+		maxSeen  int
+	)
+	// only the unranked peer holds the dataset
+	request := func(ctx context.Context, pid peer.ID, ref *dsref.Ref, wantHead bool) (string, string, *dsref.HeadInfo, error) {
+		lk.Lock()
+		asked = append(asked, pid)
+		if inFlight++; inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		lk.Unlock()
+		time.Sleep(time.Millisecond * 10)
+		lk.Lock()
+		inFlight--
+		lk.Unlock()
+		if pid != unknown {
+			return "", "", nil, dsref.ErrRefNotFound
+		}
+		*ref = dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmPath"}
+		return pid.Pretty(), "", nil, nil
+	}
+
+	// asking one peer at a time, the highest ranked peers are asked first, and
+	// lower ranked peers aren't asked once a peer resolves the reference
+	rr := n.NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.MaxConcurrentRequests = 1
+	}).(*p2pRefResolver)
+	rr.request = request
+	if _, err := rr.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "dataset"}); err != nil {
+		t.Fatal(err)
+	}
+	lk.Lock()
+	if len(asked) != 2 || asked[0] != successful || asked[1] != unknown {
+		t.Errorf("expected peers to be asked in rank order until resolved, asked: %v", asked)
+	}
+	lk.Unlock()
+
+	// requests in flight never exceed the cap
+	asked, maxSeen = nil, 0
+	rr = n.NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.MaxConcurrentRequests = 2
+	}).(*p2pRefResolver)
+	rr.request = request
+	if _, err := rr.ResolveRefDebug(ctx, dsref.Ref{Username: "peer", Name: "dataset"}); err != nil {
+		t.Fatal(err)
+	}
+	lk.Lock()
+	if len(asked) != 3 {
+		t.Errorf("expected all peers to be asked, asked: %v", asked)
+	}
+	if maxSeen != 2 {
+		t.Errorf("expected at most 2 requests in flight, got: %d", maxSeen)
+	}
+	lk.Unlock()
+
+	// disconnected peers are forgotten
+	n.stats.forget(successful)
+	n.stats.lk.Lock()
+	defer n.stats.lk.Unlock()
+	if _, ok := n.stats.attempts[successful]; ok {
+		t.Errorf("expected forgotten peer's history to be dropped")
+	}
+}
+
+func TestResolveRefStatsSkipCanceledRequests(t *testing.T) {
+	ctx := context.Background()
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, nil)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	fast := cfgtest.GetTestPeerInfo(1).PeerID
+	slow := cfgtest.GetTestPeerInfo(2).PeerID
+	busy := cfgtest.GetTestPeerInfo(3).PeerID
+	for _, pid := range []peer.ID{fast, slow, busy} {
+		markQriPeer(t, n, pid)
+	}
+	n.stats.record(slow, true)
+
+	// both the fast & slow peers hold the dataset, the slow peer's request is
+	// canceled when the fast peer answers first
+	slowDone := make(chan struct{})
+	rr := n.NewP2PRefResolver().(*p2pRefResolver)
+	// responses are reported after outcomes are recorded
+	rr.onResponse = func(res PeerResolveResult) {
+		if res.PeerID == slow {
+			close(slowDone)
+		}
+	}
+	rr.request = func(ctx context.Context, pid peer.ID, ref *dsref.Ref, wantHead bool) (string, string, *dsref.HeadInfo, error) {
+		switch pid {
+		case busy:
+			return "", "", nil, ErrPeerBusy
+		case slow:
+			<-ctx.Done()
+			return "", "", nil, ctx.Err()
+		}
+		*ref = dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmPath"}
+		return pid.Pretty(), "", nil, nil
+	}
+	if _, err := rr.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "dataset"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-slowDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected slow peer's request to be canceled")
+	}
+
+	n.stats.lk.Lock()
+	defer n.stats.lk.Unlock()
+	if n.stats.attempts[slow] != 1 || n.stats.successes[slow] != 1 {
+		t.Errorf("expected canceled request not to count against the slow peer, got %d successes in %d attempts", n.stats.successes[slow], n.stats.attempts[slow])
+	}
+	if n.stats.attempts[busy] != 0 {
+		t.Errorf("expected busy response not to be recorded, got %d attempts", n.stats.attempts[busy])
+	}
+	if n.stats.attempts[fast] != 1 || n.stats.successes[fast] != 1 {
+		t.Errorf("expected fast peer's answer to be recorded, got %d successes in %d attempts", n.stats.successes[fast], n.stats.attempts[fast])
+	}
+}
@@ -3,7 +3,11 @@ package p2p
 import (
 	"context"
 	"testing"
+	"time"
 
+	net "github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	swarm "github.com/libp2p/go-libp2p-swarm"
 	p2ptest "github.com/qri-io/qri/p2p/test"
 )
 
@@ -17,6 +21,64 @@ func asQriNodes(testPeers []p2ptest.TestablePeerNode) []*QriNode {
 	return peers
 }
 
+// connectTestPeer connects node a to node b, retrying failed dials. Test
+// nodes reuse peer IDs, and mDNS can start a dial from a to a node from
+// another test that shares b's ID. Dials to the same peer ID are shared, so
+// a stale in-flight dial fails connections to b until it completes
+func connectTestPeer(ctx context.Context, t *testing.T, a, b *QriNode) {
+	var err error
+	for i := 0; i < 20; i++ {
+		if sw, ok := a.host.Network().(*swarm.Swarm); ok {
+			sw.Backoff().Clear(b.host.ID())
+		}
+		if err = a.host.Connect(ctx, b.SimpleAddrInfo()); err == nil {
+			return
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	t.Fatalf("connecting test peers: %s", err)
+}
+
+// hasPeerID reports whether pid is in pids
+func hasPeerID(pids []peer.ID, pid peer.ID) bool {
+	for _, p := range pids {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// markQriPeer records pid as a qri peer that has completed a profile
+// exchange, without connecting to it
+func markQriPeer(t *testing.T, n *QriNode, pid peer.ID) {
+	t.Helper()
+	done := make(chan struct{})
+	close(done)
+	n.qis.peersMu.Lock()
+	n.qis.peers[pid] = done
+	n.qis.peersMu.Unlock()
+}
+
+// settleTestPeer waits out any stale dials from node a to node b, leaving the
+// nodes disconnected so a test can exercise dialing b itself. Connecting
+// starts an asynchronous qri profile exchange that can redial b, so the
+// exchange must finish before disconnecting
+func settleTestPeer(ctx context.Context, t *testing.T, a, b *QriNode) {
+	connectTestPeer(ctx, t, a, b)
+	for i := 0; i < 40 && !hasPeerID(a.ConnectedQriPeerIDs(), b.host.ID()); i++ {
+		time.Sleep(time.Millisecond * 25)
+	}
+	for i := 0; i < 40; i++ {
+		a.host.Network().ClosePeer(b.host.ID())
+		time.Sleep(time.Millisecond * 25)
+		if a.host.Network().Connectedness(b.host.ID()) != net.Connected && !hasPeerID(a.ConnectedQriPeerIDs(), b.host.ID()) {
+			return
+		}
+	}
+	t.Fatal("expected test peers to be disconnected")
+}
+
 // this test is the poster child for re-vamping how we do our p2p test networks
 func TestConnectedQriProfiles(t *testing.T) {
 	t.Skip("TODO (ramfox): test is flakey.  See comments for full details")
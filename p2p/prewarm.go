@@ -0,0 +1,85 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	net "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"github.com/qri-io/qri/dsref"
+)
+
+const (
+	// prewarmTimeout bounds the time PrewarmConnections spends finding and
+	// dialing peers
+	prewarmTimeout = time.Second * 30
+	// prewarmProviderLimit caps the number of DHT providers found per reference
+	prewarmProviderLimit = 5
+)
+
+// PrewarmConnections connects to peers likely to resolve a set of references
+// ahead of time, so later resolution & fetches don't wait on dialing. Peers
+// are found with DHT provider records for references with a path, and the
+// dataset filters of previously connected peers. Peers that can't be found or
+// dialed are skipped
+func (n *QriNode) PrewarmConnections(ctx context.Context, refs []dsref.Ref) error {
+	if !n.Online {
+		return ErrNotConnected
+	}
+	ctx, cancel := context.WithTimeout(ctx, prewarmTimeout)
+	defer cancel()
+
+	seen := map[peer.ID]bool{n.host.ID(): true}
+	var pinfos []peer.AddrInfo
+	for _, ref := range refs {
+		found, err := n.findProviders(ctx, ref)
+		if err != nil {
+			log.Debugf("finding providers for %q: %s", ref, err)
+			continue
+		}
+		for _, pinfo := range found {
+			if seen[pinfo.ID] {
+				continue
+			}
+			seen[pinfo.ID] = true
+			if n.host.Network().Connectedness(pinfo.ID) == net.Connected {
+				continue
+			}
+			pinfos = append(pinfos, pinfo)
+		}
+	}
+
+	log.Debugf("prewarming connections to %d peers", len(pinfos))
+	n.connectQriPeers(ctx, pinfos)
+	return nil
+}
+
+// findRefProviders returns peers likely to hold a reference, combining peers
+// with a dataset filter that may contain the reference & DHT providers of the
+// reference path when the node is backed by IPFS
+func (n *QriNode) findRefProviders(ctx context.Context, ref dsref.Ref) ([]peer.AddrInfo, error) {
+	var pinfos []peer.AddrInfo
+	for _, pid := range n.filters.hinted(ref) {
+		if pinfo := n.host.Peerstore().PeerInfo(pid); len(pinfo.Addrs) > 0 {
+			pinfos = append(pinfos, pinfo)
+		}
+	}
+
+	if ref.Path == "" {
+		return pinfos, nil
+	}
+	ipfsnode, err := n.IPFS()
+	if err != nil || ipfsnode.Routing == nil {
+		return pinfos, nil
+	}
+	id, err := cid.Parse(ref.Path)
+	if err != nil {
+		return pinfos, fmt.Errorf("parsing reference path: %w", err)
+	}
+	for pinfo := range ipfsnode.Routing.FindProvidersAsync(ctx, id, prewarmProviderLimit) {
+		pinfos = append(pinfos, pinfo)
+	}
+	return pinfos, nil
+}
@@ -0,0 +1,106 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	net "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"github.com/qri-io/qri/dsref"
+	p2ptest "github.com/qri-io/qri/p2p/test"
+)
+
+func TestPrewarmConnections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 2)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+	settleTestPeer(ctx, t, nodes[0], nodes[1])
+
+	// stub provider lookup, with only the second node advertising the
+	// requested dataset
+	var requested []dsref.Ref
+	nodes[0].findProviders = func(_ context.Context, ref dsref.Ref) ([]peer.AddrInfo, error) {
+		requested = append(requested, ref)
+		if ref.Human() == "test-repo-1/cities" {
+			return []peer.AddrInfo{nodes[1].SimpleAddrInfo()}, nil
+		}
+		return nil, nil
+	}
+
+	refs := []dsref.Ref{
+		{Username: "test-repo-1", Name: "cities"},
+		{Username: "test-repo-1", Name: "not_a_dataset"},
+	}
+	if err := nodes[0].PrewarmConnections(ctx, refs); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != len(refs) {
+		t.Errorf("expected providers to be found for %d refs, got %d", len(refs), len(requested))
+	}
+
+	if nodes[0].host.Network().Connectedness(nodes[1].host.ID()) != net.Connected {
+		t.Errorf("expected connection to advertising peer")
+	}
+	if nodes[0].qis.ConnectedPeerProfile(nodes[1].host.ID()) == nil {
+		t.Errorf("expected advertising peer to be upgraded to a qri peer")
+	}
+}
+
+func TestFindRefProvidersFilterHints(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 2)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+
+	f, err := nodes[1].localInitIDFilter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinfo := nodes[1].SimpleAddrInfo()
+	nodes[0].host.Peerstore().AddAddrs(pinfo.ID, pinfo.Addrs, time.Minute)
+	nodes[0].filters.set(pinfo.ID, f)
+
+	found, err := nodes[0].findRefProviders(ctx, dsref.Ref{Username: "test-repo-1", Name: "cities"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != pinfo.ID {
+		t.Errorf("expected filter hint to find peer %s, got: %v", pinfo.ID, found)
+	}
+
+	found, err = nodes[0].findRefProviders(ctx, dsref.Ref{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected empty reference to find no peers, got: %v", found)
+	}
+}
@@ -0,0 +1,126 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	ping "github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+const (
+	// peerLivenessInterval is how often connected qri peers are probed for
+	// liveness
+	peerLivenessInterval = time.Minute
+	// peerLivenessTimeout is the length of time a liveness probe will wait for
+	// a peer to respond
+	peerLivenessTimeout = time.Second * 10
+)
+
+// peerReaper tracks connected qri peers that have failed a liveness probe.
+// Connections can be half-open, keeping a peer in the connected set long after
+// it has stopped responding. Stale peers are excluded from the set of peers
+// reference resolution fans out to
+type peerReaper struct {
+	probe func(ctx context.Context, pid peer.ID) error
+
+	lk    sync.Mutex
+	stale map[peer.ID]struct{}
+}
+
+func newPeerReaper(probe func(ctx context.Context, pid peer.ID) error) *peerReaper {
+	return &peerReaper{
+		probe: probe,
+		stale: map[peer.ID]struct{}{},
+	}
+}
+
+// reap probes a set of peers concurrently, replacing the stale set with the
+// peers that failed to respond
+func (r *peerReaper) reap(ctx context.Context, pids []peer.ID) {
+	var (
+		wg      sync.WaitGroup
+		staleLk sync.Mutex
+		stale   = map[peer.ID]struct{}{}
+	)
+
+	wg.Add(len(pids))
+	for _, pid := range pids {
+		go func(pid peer.ID) {
+			defer wg.Done()
+			if err := r.probe(ctx, pid); err != nil {
+				log.Debugf("peer %q failed liveness probe: %s", pid, err)
+				staleLk.Lock()
+				stale[pid] = struct{}{}
+				staleLk.Unlock()
+			}
+		}(pid)
+	}
+	wg.Wait()
+
+	r.lk.Lock()
+	r.stale = stale
+	r.lk.Unlock()
+}
+
+// live filters a slice of peers, removing peers that failed their most recent
+// liveness probe
+func (r *peerReaper) live(pids []peer.ID) []peer.ID {
+	if r == nil {
+		return pids
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	live := make([]peer.ID, 0, len(pids))
+	for _, pid := range pids {
+		if _, ok := r.stale[pid]; !ok {
+			live = append(live, pid)
+		}
+	}
+	return live
+}
+
+// LiveQriPeerIDs returns connected qri peers, excluding peers that failed their
+// most recent liveness probe
+func (n *QriNode) LiveQriPeerIDs() []peer.ID {
+	return n.reaper.live(n.ConnectedQriPeerIDs())
+}
+
+// ReapStalePeers probes all connected qri peers for liveness, excluding peers
+// that fail to respond from reference resolution until they pass a later probe
+func (n *QriNode) ReapStalePeers(ctx context.Context) {
+	if n.reaper == nil {
+		return
+	}
+	n.reaper.reap(ctx, n.ConnectedQriPeerIDs())
+}
+
+// runPeerReaper calls ReapStalePeers every interval until the context is
+// cancelled
+func (n *QriNode) runPeerReaper(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			n.ReapStalePeers(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pingPeer uses the libp2p ping protocol as a liveness probe
+func (n *QriNode) pingPeer(ctx context.Context, pid peer.ID) error {
+	ctx, cancel := context.WithTimeout(ctx, peerLivenessTimeout)
+	defer cancel()
+
+	select {
+	case res := <-ping.Ping(ctx, n.host, pid):
+		return res.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+)
+
+func TestReapStalePeers(t *testing.T) {
+	ctx := context.Background()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, nil)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	live := cfgtest.GetTestPeerInfo(1).PeerID
+	unresponsive := cfgtest.GetTestPeerInfo(2).PeerID
+
+	// pretend both peers have completed a qri profile exchange
+	for _, pid := range []peer.ID{live, unresponsive} {
+		markQriPeer(t, n, pid)
+	}
+
+	n.reaper.probe = func(_ context.Context, pid peer.ID) error {
+		if pid == unresponsive {
+			return fmt.Errorf("timed out")
+		}
+		return nil
+	}
+
+	if got := n.LiveQriPeerIDs(); len(got) != 2 {
+		t.Errorf("expected both peers to be live before reaping, got: %v", got)
+	}
+
+	n.ReapStalePeers(ctx)
+	got := n.LiveQriPeerIDs()
+	if len(got) != 1 || got[0] != live {
+		t.Errorf("expected only responsive peer %q after reaping, got: %v", live, got)
+	}
+	if len(n.ConnectedQriPeerIDs()) != 2 {
+		t.Errorf("reaping must not drop peers from the connected set")
+	}
+
+	// a stale peer that starts responding again is restored
+	n.reaper.probe = func(context.Context, peer.ID) error { return nil }
+	n.ReapStalePeers(ctx)
+	if got := n.LiveQriPeerIDs(); len(got) != 2 {
+		t.Errorf("expected recovered peer to be live after reaping, got: %v", got)
+	}
+}
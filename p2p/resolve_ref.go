@@ -2,13 +2,19 @@ package p2p
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p-core/helpers"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/qri-io/qri/base/dsfs"
 	"github.com/qri-io/qri/dsref"
 )
 
@@ -20,62 +26,669 @@ const (
 	// ResolveRefProtocolID is the protocol on which qri nodes communicate to
 	// resolve references
 	ResolveRefProtocolID = protocol.ID("/qri/ref/0.1.0")
+	// DefaultMaxResolveHandlers is the default number of inbound resolution
+	// requests a node handles at once
+	DefaultMaxResolveHandlers = 64
+	// DefaultMaxConcurrentResolveRequests is the default number of outbound
+	// resolution requests a resolver has in flight at once
+	DefaultMaxConcurrentResolveRequests = 8
 )
 
+const (
+	// ResolveStageConnectedPeers is the dsref.Attempt stage for resolution
+	// requests sent to connected peers
+	ResolveStageConnectedPeers = "connected peers"
+	// ResolveStageLocalDiscovery is the dsref.Attempt stage for resolution
+	// requests sent after discovering local peers
+	ResolveStageLocalDiscovery = "local discovery"
+)
+
+// ErrPeerBusy is returned when a peer rejects a resolution request because
+// it's already handling as many requests as it allows
+var ErrPeerBusy = errors.New("p2p: peer is busy")
+
+// ErrNoResolver is returned when a peer rejects a resolution request because
+// it doesn't serve reference resolution
+var ErrNoResolver = errors.New("p2p: peer has no resolver")
+
+// ErrResponderMismatch is returned when a response to a resolution request
+// identifies a different peer than the one the request was sent to
+var ErrResponderMismatch = errors.New("p2p: responding peer doesn't match requested peer")
+
+// errNotAsked is the response error for peers skipped because another peer
+// already resolved the reference
+var errNotAsked = errors.New("p2p: peer not asked, reference already resolved")
+
+// ErrNoAgreement is returned when resolution requires a number of peers to
+// agree on a resolved reference, and too few do
+var ErrNoAgreement = errors.New("p2p: peers didn't agree on a reference")
+
+// ErrChecksumMismatch is returned when a peer responds to a reference
+// resolution request with a manifest checksum that doesn't match the checksum
+// of the manifest the requester fetches
+var ErrChecksumMismatch = errors.New("p2p: manifest checksum mismatch")
+
 type p2pRefResolver struct {
-	node *QriNode
+	node           *QriNode
+	verifyChecksum bool
+	// checksum calculates the manifest checksum for a dataset path, overridden
+	// in tests
+	checksum func(ctx context.Context, path string) (string, error)
+	// selectPeers chooses which eligible peers to query. nil queries all peers
+	selectPeers PeerSelector
+	// discover finds local peers to connect to when no peers are eligible for
+	// a request. nil disables on-demand discovery
+	discover func(ctx context.Context) ([]peer.AddrInfo, error)
+	// minAgreement is the number of peers that must agree on a resolved path
+	minAgreement int
+	// onResponse is called with each peer response as it arrives. nil disables
+	// streaming responses
+	onResponse func(PeerResolveResult)
+	// maxConcurrent caps the number of requests in flight at once. Values less
+	// than 1 use DefaultMaxConcurrentResolveRequests
+	maxConcurrent int
+	// request asks a single peer to resolve a reference, overridden in tests.
+	// nil uses resolveRefRequest
+	request func(ctx context.Context, pid peer.ID, ref *dsref.Ref, wantHead bool) (source, profileID string, head *dsref.HeadInfo, err error)
 }
 
+// assert at compile time that p2pRefResolver is a HeadResolver
+var _ dsref.HeadResolver = (*p2pRefResolver)(nil)
+
 type resolveRefRes struct {
-	ref    *dsref.Ref
-	source string
+	pid       peer.ID
+	profileID string
+	ref       *dsref.Ref
+	source    string
+	head      *dsref.HeadInfo
+	found     bool
+	err       error
+	latency   time.Duration
+}
+
+const (
+	// resolveRefStatusFound indicates the responding peer resolved a reference
+	resolveRefStatusFound = "found"
+	// resolveRefStatusNotFound indicates the responding peer couldn't resolve a
+	// reference
+	resolveRefStatusNotFound = "not-found"
+	// resolveRefStatusBusy indicates the responding peer is handling too many
+	// requests to resolve the reference
+	resolveRefStatusBusy = "busy"
+	// resolveRefStatusNoResolver indicates the responding peer has no local
+	// resolver, and doesn't serve reference resolution
+	resolveRefStatusNoResolver = "no-resolver"
+)
+
+// resolveRefMessage is the wire format for requests & responses on the
+// ResolveRefProtocolID. Embedding dsref.Ref keeps messages compatible with
+// peers that send & receive bare references
+type resolveRefMessage struct {
+	dsref.Ref
+	// WantChecksum is set on requests to ask the responder to include a
+	// manifest checksum in the response
+	WantChecksum bool `json:"wantChecksum,omitempty"`
+	// Checksum is the manifest checksum for Ref.Path, set on responses
+	Checksum string `json:"checksum,omitempty"`
+	// Status reports whether the responder resolved the reference, set on
+	// responses
+	Status string `json:"status,omitempty"`
+	// WantHead is set on requests to ask the responder to include head
+	// metadata in the response
+	WantHead bool `json:"wantHead,omitempty"`
+	// Head is metadata for the dataset version at Ref.Path, set on responses
+	// when the responder has the dataset locally
+	Head *dsref.HeadInfo `json:"head,omitempty"`
+	// ResponderPeerID & ResponderProfileID identify the peer that answered a
+	// request, set on responses. They're distinct from Ref.ProfileID, which
+	// identifies the dataset author
+	ResponderPeerID    string `json:"responderPeerID,omitempty"`
+	ResponderProfileID string `json:"responderProfileID,omitempty"`
+}
+
+// found reports whether a response message is a successful resolution.
+// Responses from peers that predate response statuses are considered found
+// if the reference is complete
+func (msg *resolveRefMessage) found() bool {
+	if msg.Status == "" {
+		return msg.Ref.Complete()
+	}
+	return msg.Status == resolveRefStatusFound
 }
 
 func (rr *p2pRefResolver) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
+	source, _, err := rr.resolveRef(ctx, ref, false)
+	return source, err
+}
+
+// ResolveRefHead resolves a reference, asking peers to include commit
+// metadata for the resolved version in their response
+func (rr *p2pRefResolver) ResolveRefHead(ctx context.Context, ref *dsref.Ref) (string, *dsref.HeadInfo, error) {
+	return rr.resolveRef(ctx, ref, true)
+}
+
+// ResolveRefStream resolves a reference, calling onResponse with each peer
+// response as it arrives. Peers may respond with a partial reference before
+// one responds with a complete answer. Calls to onResponse are serialized,
+// and never happen after ResolveRefStream returns
+func (rr *p2pRefResolver) ResolveRefStream(ctx context.Context, ref *dsref.Ref, onResponse func(PeerResolveResult)) (string, error) {
+	if rr == nil || onResponse == nil {
+		return rr.ResolveRef(ctx, ref)
+	}
+
+	var (
+		lk   sync.Mutex
+		done bool
+	)
+	streamer := *rr
+	streamer.onResponse = func(res PeerResolveResult) {
+		lk.Lock()
+		defer lk.Unlock()
+		if !done {
+			onResponse(res)
+		}
+	}
+	defer func() {
+		lk.Lock()
+		done = true
+		lk.Unlock()
+	}()
+
+	source, _, err := streamer.resolveRef(ctx, ref, false)
+	return source, err
+}
+
+func (rr *p2pRefResolver) resolveRef(ctx context.Context, ref *dsref.Ref, wantHead bool) (string, *dsref.HeadInfo, error) {
 	log.Debugf("p2p.ResolveRef ref=%q", ref)
 	if rr == nil || rr.node == nil {
-		return "", dsref.ErrRefNotFound
+		return "", nil, dsref.ErrRefNotFound
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
 	}
-	refCp := ref.Copy()
 	streamCtx, cancel := context.WithTimeout(ctx, p2pRefResolverTimeout)
 	defer cancel()
 
-	connectedPids := rr.node.ConnectedQriPeerIDs()
-	numReqs := len(connectedPids)
+	pids := rr.fanOutPeerIDs(*ref)
+	source, head, err := rr.attempt(streamCtx, ResolveStageConnectedPeers, pids, ref, wantHead)
+	if len(pids) == 0 && rr.discoverPeers(streamCtx) {
+		source, head, err = rr.attempt(streamCtx, ResolveStageLocalDiscovery, rr.fanOutPeerIDs(*ref), ref, wantHead)
+	}
+	return source, head, err
+}
+
+// attempt makes one pass at resolving a reference by asking the given peers,
+// recording the outcome to any dsref.Attempts attached to the context
+func (rr *p2pRefResolver) attempt(ctx context.Context, stage string, pids []peer.ID, ref *dsref.Ref, wantHead bool) (source string, head *dsref.HeadInfo, err error) {
+	start := time.Now()
+	if len(pids) == 0 {
+		err = dsref.ErrRefNotFound
+	} else {
+		resCh := rr.requestPeers(ctx, pids, *ref, wantHead, rr.minAgreement < 2)
+		source, head, err = rr.await(ctx, ref, resCh, len(pids))
+	}
+	dsref.AttemptsFromContext(ctx).Record(dsref.Attempt{
+		Stage:    stage,
+		Requests: len(pids),
+		Found:    err == nil,
+		Err:      err,
+		Duration: time.Since(start),
+	})
+	return source, head, err
+}
+
+// discoverPeers runs on-demand local peer discovery if it's enabled,
+// connecting to any peers found. It reports if any peers were found
+func (rr *p2pRefResolver) discoverPeers(ctx context.Context) bool {
+	if rr.discover == nil || rr.node.host == nil {
+		return false
+	}
+	pinfos, err := rr.discover(ctx)
+	if err != nil {
+		log.Debugf("p2p.ResolveRef - local peer discovery failed: %s", err)
+		return false
+	}
+	if len(pinfos) == 0 {
+		return false
+	}
+	log.Debugf("p2p.ResolveRef - discovered %d local peers", len(pinfos))
+	rr.node.connectQriPeers(ctx, pinfos)
+	return true
+}
+
+// RefCandidate is a single peer's answer to a reference resolution request
+type RefCandidate struct {
+	Ref    dsref.Ref
+	Source string
+	// PeerID & ProfileID identify the peer that answered. ProfileID is empty
+	// if the peer didn't identify its profile and isn't known to this node
+	PeerID    peer.ID
+	ProfileID string
+}
+
+// ResolveRefAll asks all live connected peers to resolve a reference,
+// collecting every distinct complete answer received before the timeout.
+// In a federated setting a human-friendly name can map to different paths
+// on different peers. Candidates with identical paths are deduplicated
+func (rr *p2pRefResolver) ResolveRefAll(ctx context.Context, ref dsref.Ref) ([]RefCandidate, error) {
+	log.Debugf("p2p.ResolveRefAll ref=%q", ref)
+	if rr == nil || rr.node == nil {
+		return nil, dsref.ErrRefNotFound
+	}
+	streamCtx, cancel := context.WithTimeout(ctx, p2pRefResolverTimeout)
+	defer cancel()
+
+	resCh, numReqs := rr.requestAll(streamCtx, ref, false)
 	if numReqs == 0 {
-		return "", dsref.ErrRefNotFound
+		return nil, dsref.ErrRefNotFound
+	}
+	return collectResolveRefCandidates(streamCtx, resCh, numReqs)
+}
+
+// requestAll sends a resolution request to each live connected qri peer,
+// returning a channel of responses and the number of requests sent. wantHead
+// asks peers to include head metadata in responses
+func (rr *p2pRefResolver) requestAll(ctx context.Context, ref dsref.Ref, wantHead bool) (<-chan resolveRefRes, int) {
+	connectedPids := rr.fanOutPeerIDs(ref)
+	return rr.requestPeers(ctx, connectedPids, ref, wantHead, false), len(connectedPids)
+}
+
+// requestPeers sends a resolution request to each of the given peers,
+// returning a channel of responses. At most maxConcurrent requests are in
+// flight at once. Requests are dispatched in the order peers are given as
+// slots free up, so higher ranked peers are asked first. With stopOnFound set,
+// peers that haven't been asked when a peer resolves the reference are
+// skipped. Skipped peers, and peers that aren't asked before the context is
+// done, respond with an error without being asked
+func (rr *p2pRefResolver) requestPeers(ctx context.Context, pids []peer.ID, ref dsref.Ref, wantHead, stopOnFound bool) <-chan resolveRefRes {
+	trace := dsref.TraceFromContext(ctx)
+	resCh := make(chan resolveRefRes, len(pids))
+	slots := make(chan struct{}, rr.concurrency())
+	var (
+		foundLk sync.Mutex
+		found   bool
+	)
+	isFound := func() bool {
+		foundLk.Lock()
+		defer foundLk.Unlock()
+		return found
 	}
 
-	resCh := make(chan resolveRefRes, numReqs)
-	for _, pid := range connectedPids {
-		go func(pid peer.ID, reqRef dsref.Ref) {
-			source := rr.resolveRefRequest(streamCtx, pid, &reqRef)
-			resCh <- resolveRefRes{
-				ref:    &reqRef,
-				source: source,
+	go func() {
+		for _, pid := range pids {
+			reqRef := ref.Copy()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				resCh <- resolveRefRes{pid: pid, ref: &reqRef, err: ctx.Err()}
+				continue
+			}
+			if stopOnFound && isFound() {
+				<-slots
+				resCh <- resolveRefRes{pid: pid, ref: &reqRef, err: errNotAsked}
+				continue
 			}
-		}(pid, refCp.Copy())
+
+			go func(pid peer.ID, reqRef dsref.Ref) {
+				defer func() { <-slots }()
+				start := time.Now()
+				source, profileID, head, err := rr.requestPeer(ctx, pid, &reqRef, wantHead)
+				end := time.Now()
+				if err == nil {
+					foundLk.Lock()
+					found = true
+					foundLk.Unlock()
+				}
+				if countsTowardStats(ctx, err) {
+					rr.node.stats.record(pid, err == nil)
+				}
+				trace.Record(dsref.Span{
+					Stage:  "p2p peer " + pid.Pretty(),
+					Start:  start,
+					End:    end,
+					Source: source,
+					Err:    err,
+				})
+				if rr.onResponse != nil {
+					rr.onResponse(PeerResolveResult{
+						PeerID:    pid,
+						ProfileID: profileID,
+						Ref:       reqRef.Copy(),
+						Found:     err == nil,
+						Err:       err,
+						Latency:   end.Sub(start),
+					})
+				}
+				resCh <- resolveRefRes{
+					pid:       pid,
+					profileID: profileID,
+					ref:       &reqRef,
+					source:    source,
+					head:      head,
+					found:     err == nil,
+					err:       err,
+					latency:   end.Sub(start),
+				}
+			}(pid, reqRef)
+		}
+	}()
+	return resCh
+}
+
+// countsTowardStats reports whether the outcome of a request reflects on the
+// peer that was asked. Requests cut short because the resolver stopped
+// waiting, and requests busy peers turn away, don't
+func countsTowardStats(ctx context.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
 	}
+	return !errors.Is(err, ErrPeerBusy)
+}
 
-	for {
+// concurrency is the number of requests the resolver sends at once
+func (rr *p2pRefResolver) concurrency() int {
+	if rr.maxConcurrent > 0 {
+		return rr.maxConcurrent
+	}
+	return DefaultMaxConcurrentResolveRequests
+}
+
+// requestPeer asks a single peer to resolve a reference
+func (rr *p2pRefResolver) requestPeer(ctx context.Context, pid peer.ID, ref *dsref.Ref, wantHead bool) (string, string, *dsref.HeadInfo, error) {
+	if rr.request != nil {
+		return rr.request(ctx, pid, ref, wantHead)
+	}
+	return rr.resolveRefRequest(ctx, pid, ref, wantHead)
+}
+
+// PeerResolveResult is the outcome of asking a single peer to resolve a
+// reference
+type PeerResolveResult struct {
+	PeerID peer.ID
+	// ProfileID is the profile of the peer that answered, empty if the peer
+	// didn't respond, didn't identify its profile, and isn't known to this node
+	ProfileID string
+	// Ref is the reference as returned by the peer, which may be partially
+	// resolved when the peer couldn't find it
+	Ref dsref.Ref
+	// Found is true when the peer responded with a complete reference
+	Found bool
+	// Err is the reason the peer didn't resolve the reference, if any. Peers
+	// that didn't respond before the timeout have a context error
+	Err     error
+	Latency time.Duration
+}
+
+// ResolveRefDebug asks all live connected peers to resolve a reference,
+// returning the outcome of every request instead of stopping at the first
+// complete answer. Results are ordered the same way peers are queried.
+// ResolveRefDebug is meant for diagnosing connectivity, and only returns an
+// error if the resolver can't make requests at all
+func (rr *p2pRefResolver) ResolveRefDebug(ctx context.Context, ref dsref.Ref) ([]PeerResolveResult, error) {
+	log.Debugf("p2p.ResolveRefDebug ref=%q", ref)
+	if rr == nil || rr.node == nil {
+		return nil, dsref.ErrRefNotFound
+	}
+	streamCtx, cancel := context.WithTimeout(ctx, p2pRefResolverTimeout)
+	defer cancel()
+
+	pids := rr.fanOutPeerIDs(ref)
+	start := time.Now()
+	resCh := rr.requestPeers(streamCtx, pids, ref, false, false)
+	return collectPeerResolveResults(streamCtx, pids, start, ref, resCh), nil
+}
+
+// collectPeerResolveResults gathers a result for each requested peer,
+// returning once all peers respond or the context is done. Peers that haven't
+// responded by then are reported with the context error
+func collectPeerResolveResults(ctx context.Context, pids []peer.ID, start time.Time, ref dsref.Ref, resCh <-chan resolveRefRes) []PeerResolveResult {
+	got := make(map[peer.ID]resolveRefRes, len(pids))
+	var ctxErr error
+	for ctxErr == nil && len(got) < len(pids) {
+		select {
+		case res := <-resCh:
+			got[res.pid] = res
+		case <-ctx.Done():
+			log.Debug("p2p.ResolveRefDebug context canceled or timed out before all peers responded")
+			ctxErr = ctx.Err()
+		}
+	}
+
+	results := make([]PeerResolveResult, len(pids))
+	for i, pid := range pids {
+		res, ok := got[pid]
+		if !ok {
+			results[i] = PeerResolveResult{
+				PeerID:  pid,
+				Ref:     ref.Copy(),
+				Err:     ctxErr,
+				Latency: time.Since(start),
+			}
+			continue
+		}
+		results[i] = PeerResolveResult{
+			PeerID:    pid,
+			ProfileID: res.profileID,
+			Ref:       *res.ref,
+			Found:     res.found,
+			Err:       res.err,
+			Latency:   res.latency,
+		}
+	}
+	return results
+}
+
+// fanOutPeerIDs returns the set of peers to send resolution requests to,
+// ordered by past resolution success rate. The node's own ID is excluded,
+// which can appear in the connected set through relays or misconfiguration.
+// Peers with a dataset filter showing they don't hold the reference are also
+// excluded. The resolver's peer selector picks the final subset
+func (rr *p2pRefResolver) fanOutPeerIDs(ref dsref.Ref) []peer.ID {
+	pids := rr.node.stats.order(rr.node.filters.prune(rr.node.LiveQriPeerIDs(), ref))
+	if rr.node.host != nil {
+		self := rr.node.host.ID()
+		filtered := make([]peer.ID, 0, len(pids))
+		for _, pid := range pids {
+			if pid != self {
+				filtered = append(filtered, pid)
+			}
+		}
+		pids = filtered
+	}
+	if rr.selectPeers != nil {
+		pids = rr.selectPeers(pids)
+	}
+	return pids
+}
+
+// collectResolveRefCandidates gathers distinct complete responses from
+// numReqs peer requests, returning once all peers respond or the context is
+// done. It's only an error if no peer responds with a complete reference
+func collectResolveRefCandidates(ctx context.Context, resCh <-chan resolveRefRes, numReqs int) ([]RefCandidate, error) {
+	var (
+		candidates []RefCandidate
+		seen       = map[string]struct{}{}
+	)
+
+	for numReqs > 0 {
 		select {
 		case res := <-resCh:
 			numReqs--
-			if !res.ref.Complete() && numReqs == 0 {
-				return "", dsref.ErrRefNotFound
+			if !res.found {
+				continue
+			}
+			if _, ok := seen[res.ref.Path]; ok {
+				continue
+			}
+			seen[res.ref.Path] = struct{}{}
+			candidates = append(candidates, res.candidate())
+		case <-ctx.Done():
+			log.Debug("p2p.ResolveRefAll context canceled or timed out before all peers responded")
+			if len(candidates) == 0 {
+				return nil, fmt.Errorf("p2p.ResolveRefAll context: %w", ctx.Err())
 			}
-			if res.ref.Complete() {
+			return candidates, nil
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, dsref.ErrRefNotFound
+	}
+	return candidates, nil
+}
+
+// candidate converts a response into a RefCandidate
+func (res resolveRefRes) candidate() RefCandidate {
+	return RefCandidate{Ref: *res.ref, Source: res.source, PeerID: res.pid, ProfileID: res.profileID}
+}
+
+// PartialResolutionError is returned by the p2p resolver when resolution
+// times out after peers have responded with an incomplete reference. Ref
+// holds the most complete reference seen before the timeout
+type PartialResolutionError struct {
+	Ref dsref.Ref
+	Err error
+}
+
+// Error implements the error interface
+func (e *PartialResolutionError) Error() string {
+	return fmt.Sprintf("p2p.ResolveRef context: %s. most complete partial result: %q", e.Err, e.Ref)
+}
+
+// Unwrap returns the underlying context error
+func (e *PartialResolutionError) Unwrap() error { return e.Err }
+
+// await collects responses to resolution requests, requiring agreement
+// between peers if the resolver is configured to
+func (rr *p2pRefResolver) await(ctx context.Context, ref *dsref.Ref, resCh <-chan resolveRefRes, numReqs int) (string, *dsref.HeadInfo, error) {
+	if rr.minAgreement > 1 {
+		return awaitResolveRefAgreement(ctx, ref, resCh, numReqs, rr.minAgreement)
+	}
+	return awaitResolveRefResults(ctx, ref, resCh, numReqs)
+}
+
+// AgreementError is returned when fewer peers than required agree on the
+// path a reference resolves to. Candidates holds the complete response of
+// each peer that answered, including answers that disagree
+type AgreementError struct {
+	Required   int
+	Candidates []RefCandidate
+	// Err is the context error if the request timed out before enough peers
+	// agreed
+	Err error
+}
+
+// Error implements the error interface
+func (e *AgreementError) Error() string {
+	msg := fmt.Sprintf("%s: %d peers must agree, got %d complete answers", ErrNoAgreement, e.Required, len(e.Candidates))
+	for _, c := range e.Candidates {
+		msg += fmt.Sprintf("\n  %s: %s", c.Source, c.Ref.Path)
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf("\n%s", e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns ErrNoAgreement
+func (e *AgreementError) Unwrap() error { return ErrNoAgreement }
+
+// awaitResolveRefAgreement collects responses from numReqs peer requests
+// until k peers respond with the same complete path, setting ref to the agreed
+// response. Answers that disagree with the agreed path are logged. If k peers
+// don't agree an *AgreementError lists every answer received
+func awaitResolveRefAgreement(ctx context.Context, ref *dsref.Ref, resCh <-chan resolveRefRes, numReqs, k int) (string, *dsref.HeadInfo, error) {
+	var (
+		byPath     = map[string][]resolveRefRes{}
+		candidates []RefCandidate
+	)
+
+	for numReqs > 0 {
+		select {
+		case res := <-resCh:
+			numReqs--
+			if !res.found {
+				continue
+			}
+			candidates = append(candidates, res.candidate())
+			agreed := append(byPath[res.ref.Path], res)
+			byPath[res.ref.Path] = agreed
+			if len(agreed) < k {
+				continue
+			}
+			for _, c := range candidates {
+				if c.Ref.Path != res.ref.Path {
+					log.Warnf("p2p.ResolveRef - peer %q disagrees with %d peers resolving %q. got path %q, agreed path %q", c.Source, k, ref, c.Ref.Path, res.ref.Path)
+				}
+			}
+			*ref = *agreed[0].ref
+			return agreed[0].source, agreed[0].head, nil
+		case <-ctx.Done():
+			log.Debug("p2p.ResolveRef context canceled or timed out before peers agreed")
+			return "", nil, &AgreementError{Required: k, Candidates: candidates, Err: ctx.Err()}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", nil, dsref.ErrRefNotFound
+	}
+	return "", nil, &AgreementError{Required: k, Candidates: candidates}
+}
+
+// awaitResolveRefResults collects responses from numReqs peer requests,
+// setting ref to the first complete response & returning any head metadata it
+// carried. If no peer resolves the ref and any response failed checksum
+// verification, ErrChecksumMismatch is returned
+func awaitResolveRefResults(ctx context.Context, ref *dsref.Ref, resCh <-chan resolveRefRes, numReqs int) (string, *dsref.HeadInfo, error) {
+	var (
+		partial     = ref.Copy()
+		checksumErr error
+	)
+
+	for {
+		select {
+		case res := <-resCh:
+			numReqs--
+			if errors.Is(res.err, ErrChecksumMismatch) {
+				checksumErr = res.err
+			} else if res.found {
 				*ref = *res.ref
-				return res.source, nil
+				return res.source, res.head, nil
 			}
-		case <-streamCtx.Done():
+			if populatedFieldCount(*res.ref) > populatedFieldCount(partial) {
+				partial = res.ref.Copy()
+			}
+			if numReqs == 0 {
+				if checksumErr != nil {
+					return "", nil, checksumErr
+				}
+				return "", nil, dsref.ErrRefNotFound
+			}
+		case <-ctx.Done():
 			log.Debug("p2p.ResolveRef context canceled or timed out before resolving ref")
-			return "", fmt.Errorf("p2p.ResolveRef context: %w", streamCtx.Err())
+			if populatedFieldCount(partial) > populatedFieldCount(*ref) {
+				return "", nil, &PartialResolutionError{Ref: partial, Err: ctx.Err()}
+			}
+			return "", nil, fmt.Errorf("p2p.ResolveRef context: %w", ctx.Err())
+		}
+	}
+}
+
+// populatedFieldCount is a measure of how complete a reference is
+func populatedFieldCount(ref dsref.Ref) (n int) {
+	for _, f := range []string{ref.InitID, ref.Username, ref.ProfileID, ref.Name, ref.Path} {
+		if f != "" {
+			n++
 		}
 	}
+	return n
 }
 
-func (rr *p2pRefResolver) resolveRefRequest(ctx context.Context, pid peer.ID, ref *dsref.Ref) string {
+func (rr *p2pRefResolver) resolveRefRequest(ctx context.Context, pid peer.ID, ref *dsref.Ref, wantHead bool) (string, string, *dsref.HeadInfo, error) {
 	var (
 		err error
 		s   network.Stream
@@ -94,28 +707,105 @@ func (rr *p2pRefResolver) resolveRefRequest(ctx context.Context, pid peer.ID, re
 	s, err = rr.node.Host().NewStream(ctx, pid, ResolveRefProtocolID)
 	if err != nil {
 		log.Debugf("p2p.ResolveRef - error opening resolve ref stream to peer %q: %s", pid, err)
-		return ""
+		return "", "", nil, err
 	}
 
-	err = sendRef(s, ref)
+	err = sendRef(s, &resolveRefMessage{Ref: *ref, WantChecksum: rr.verifyChecksum, WantHead: wantHead})
 	if err != nil {
 		log.Debugf("p2p.ResolveRef - error sending request ref to %q: %s", pid, err)
-		return ""
+		return "", "", nil, err
 	}
 
-	receivedRef, err := receiveRef(s)
+	res, err := receiveRef(s)
 	if err != nil {
 		log.Debugf("p2p.ResolveRef - error reading ref message from %q: %s", pid, err)
+		return "", "", nil, err
+	}
+
+	if res.Status == resolveRefStatusBusy {
+		log.Debugf("p2p.ResolveRef - peer %q is busy", pid)
+		return "", "", nil, ErrPeerBusy
+	}
+	if res.Status == resolveRefStatusNoResolver {
+		log.Debugf("p2p.ResolveRef - peer %q has no resolver", pid)
+		return "", "", nil, ErrNoResolver
+	}
+
+	source, profileID, err := rr.node.responder(pid, res)
+	if err != nil {
+		log.Debugf("p2p.ResolveRef - rejecting response from %q: %s", pid, err)
+		return "", "", nil, err
+	}
+
+	if !res.found() {
+		log.Debugf("p2p.ResolveRef - peer %q could not resolve ref", pid)
+		*ref = res.Ref
+		return "", "", nil, dsref.ErrRefNotFound
+	}
+
+	if rr.verifyChecksum {
+		if err := rr.verifyResponseChecksum(ctx, res); err != nil {
+			log.Debugf("p2p.ResolveRef - rejecting response from %q: %s", pid, err)
+			return "", "", nil, err
+		}
+	}
+
+	*ref = res.Ref
+	return source, profileID, res.Head, nil
+}
+
+// responder checks the identity a response carries matches the peer the
+// request was sent to, returning the responder's peer ID as the source of the
+// response along with the responder's profile ID. A claimed profile must match
+// the profile this node knows the peer by, if any. Peers that predate
+// responder identity are identified by the peer the request was sent to
+func (q *QriNode) responder(pid peer.ID, res *resolveRefMessage) (source, profileID string, err error) {
+	if res.ResponderPeerID != "" && res.ResponderPeerID != pid.Pretty() {
+		return "", "", fmt.Errorf("%w: sent request to %q, response from %q", ErrResponderMismatch, pid, res.ResponderPeerID)
+	}
+	known := q.peerProfileID(pid)
+	if res.ResponderProfileID == "" {
+		return pid.Pretty(), known, nil
+	}
+	if known != "" && res.ResponderProfileID != known {
+		return "", "", fmt.Errorf("%w: peer %q has profile %q, response claims profile %q", ErrResponderMismatch, pid, known, res.ResponderProfileID)
+	}
+	return pid.Pretty(), res.ResponderProfileID, nil
+}
+
+// peerProfileID returns the ID of the profile this node knows a peer by,
+// empty if the peer's profile is unknown
+func (q *QriNode) peerProfileID(pid peer.ID) string {
+	if q.Repo == nil {
+		return ""
+	}
+	pro, err := q.Repo.Profiles().PeerProfile(pid)
+	if err != nil {
 		return ""
 	}
-	*ref = *receivedRef
-	return pid.Pretty()
+	return pro.ID.String()
+}
+
+// verifyResponseChecksum checks the checksum included in a response matches
+// the checksum of the manifest for the response path
+func (rr *p2pRefResolver) verifyResponseChecksum(ctx context.Context, res *resolveRefMessage) error {
+	if res.Checksum == "" {
+		return fmt.Errorf("%w: response for %q has no checksum", ErrChecksumMismatch, res.Ref)
+	}
+	got, err := rr.checksum(ctx, res.Ref.Path)
+	if err != nil {
+		return fmt.Errorf("%w: calculating checksum for %q: %s", ErrChecksumMismatch, res.Ref.Path, err)
+	}
+	if got != res.Checksum {
+		return fmt.Errorf("%w: %q responded with %q, fetched manifest has checksum %q", ErrChecksumMismatch, res.Ref.Path, res.Checksum, got)
+	}
+	return nil
 }
 
-func sendRef(s network.Stream, ref *dsref.Ref) error {
+func sendRef(s network.Stream, msg *resolveRefMessage) error {
 	ws := WrapStream(s)
 
-	if err := ws.enc.Encode(&ref); err != nil {
+	if err := ws.enc.Encode(msg); err != nil {
 		return fmt.Errorf("error encoding dsref.Ref to wrapped stream: %s", err)
 	}
 
@@ -126,30 +816,122 @@ func sendRef(s network.Stream, ref *dsref.Ref) error {
 	return nil
 }
 
-func receiveRef(s network.Stream) (*dsref.Ref, error) {
+func receiveRef(s network.Stream) (*resolveRefMessage, error) {
 	ws := WrapStream(s)
-	ref := &dsref.Ref{}
-	if err := ws.dec.Decode(ref); err != nil {
+	msg := &resolveRefMessage{}
+	if err := ws.dec.Decode(msg); err != nil {
 		return nil, fmt.Errorf("error decoding dsref.Ref from wrapped stream: %s", err)
 	}
-	return ref, nil
+	return msg, nil
+}
+
+// manifestChecksum returns the hex-encoded sha256 sum of the manifest for a
+// dataset path
+func (q *QriNode) manifestChecksum(ctx context.Context, path string) (string, error) {
+	m, err := q.NewManifest(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// headInfo loads commit metadata for a dataset path from the local store
+func (q *QriNode) headInfo(ctx context.Context, path string) (*dsref.HeadInfo, error) {
+	if q.Repo == nil {
+		return nil, fmt.Errorf("node has no repo")
+	}
+	ds, err := dsfs.LoadDataset(ctx, q.Repo.Store(), path)
+	if err != nil {
+		return nil, err
+	}
+	head := &dsref.HeadInfo{}
+	if ds.Commit != nil {
+		head.CommitTitle = ds.Commit.Title
+		head.CommitTime = ds.Commit.Timestamp
+	}
+	if ds.Structure != nil {
+		head.BodySize = ds.Structure.Length
+	}
+	return head, nil
+}
+
+// P2PRefResolverOptions configures a p2p reference resolver
+type P2PRefResolverOptions struct {
+	// VerifyChecksum asks responding peers to include a checksum of the
+	// dataset manifest, rejecting responses with a checksum that doesn't match
+	// the manifest fetched by this node
+	VerifyChecksum bool
+	// PeerSelector chooses which connected peers to query. The default queries
+	// all peers
+	PeerSelector PeerSelector
+	// DiscoverLocalPeers runs an on-demand mDNS query for local qri peers when
+	// there are no connected peers to ask, connecting to any peers found
+	// before fanning out. This makes resolution work on a LAN with no
+	// bootstrap peers
+	DiscoverLocalPeers bool
+	// MinAgreement is the number of peers that must respond with the same
+	// complete path before a resolution is accepted, guarding against a
+	// single peer responding with a bad path. Values less than 2 accept the
+	// first complete response
+	MinAgreement int
+	// MaxConcurrentRequests caps the number of peers asked at once. Peers are
+	// asked in order of past success rate as earlier requests finish. Values
+	// less than 1 use DefaultMaxConcurrentResolveRequests
+	MaxConcurrentRequests int
 }
 
 // NewP2PRefResolver creates a resolver backed by a qri node
-func (q *QriNode) NewP2PRefResolver() dsref.Resolver {
-	return &p2pRefResolver{node: q}
+func (q *QriNode) NewP2PRefResolver(opts ...func(o *P2PRefResolverOptions)) dsref.Resolver {
+	o := &P2PRefResolverOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	rr := &p2pRefResolver{
+		node:           q,
+		verifyChecksum: o.VerifyChecksum,
+		checksum:       q.manifestChecksum,
+		selectPeers:    o.PeerSelector,
+		minAgreement:   o.MinAgreement,
+		maxConcurrent:  o.MaxConcurrentRequests,
+	}
+	if o.DiscoverLocalPeers {
+		rr.discover = q.discoverLocalPeers
+	}
+	return rr
+}
+
+// ResolveRefAll resolves a reference against all connected peers, returning
+// every distinct candidate resolution
+func (q *QriNode) ResolveRefAll(ctx context.Context, ref dsref.Ref) ([]RefCandidate, error) {
+	rr := &p2pRefResolver{node: q, checksum: q.manifestChecksum}
+	return rr.ResolveRefAll(ctx, ref)
+}
+
+// ResolveRefStream resolves a reference against connected peers, calling
+// onResponse with each peer response as it arrives
+func (q *QriNode) ResolveRefStream(ctx context.Context, ref *dsref.Ref, onResponse func(PeerResolveResult)) (string, error) {
+	rr := &p2pRefResolver{node: q, checksum: q.manifestChecksum}
+	return rr.ResolveRefStream(ctx, ref, onResponse)
+}
+
+// ResolveRefDebug resolves a reference against all connected peers,
+// returning the outcome of each peer's request
+func (q *QriNode) ResolveRefDebug(ctx context.Context, ref dsref.Ref) ([]PeerResolveResult, error) {
+	rr := &p2pRefResolver{node: q, checksum: q.manifestChecksum}
+	return rr.ResolveRefDebug(ctx, ref)
 }
 
 // ResolveRefHandler is a handler func that belongs on the QriNode
 // it handles request made on the `ResolveRefProtocol`
 func (q *QriNode) resolveRefHandler(s network.Stream) {
-	if q.localResolver == nil {
-		log.Errorf("p2p.ResolverRef - qri node has no local resolver, and so cannot handle ref resolution")
-		return
-	}
 	var (
 		err error
-		ref *dsref.Ref
+		req *resolveRefMessage
 	)
 	ctx, cancel := context.WithTimeout(context.Background(), p2pRefResolverTimeout)
 	defer func() {
@@ -165,22 +947,142 @@ func (q *QriNode) resolveRefHandler(s network.Stream) {
 	log.Debugf("p2p.resolveRefHandler received a ref request from %s %s", p, s.Conn().RemoteMultiaddr())
 
 	// get ref from stream
-	ref, err = receiveRef(s)
+	req, err = receiveRef(s)
 	if err != nil {
 		log.Debugf("p2p.resolveRefHandler - error reading ref message from %q: %s", p, err)
 		return
 	}
 
-	// try to resolve this ref locally
-	_, err = q.localResolver.ResolveRef(ctx, ref)
-	if err != nil {
-		log.Debugf("p2p.resolveRefHandler - error resolving ref locally: %s", err)
+	var res *resolveRefMessage
+	if p == q.host.ID() {
+		// respond instead of dropping the request so the requester doesn't wait
+		// out its timeout
+		log.Debugf("p2p.resolveRefHandler - rejecting ref request from self")
+		res = &resolveRefMessage{Ref: req.Ref, Status: resolveRefStatusNotFound}
+		res.ResponderPeerID, res.ResponderProfileID = q.responderIdentity()
+	} else {
+		res = q.serveResolveRef(ctx, req)
 	}
-
-	log.Debugf("p2p.resolveRefHandler %q sending ref %v to peer %q", q.host.ID(), ref, p)
-	err = sendRef(s, ref)
+	log.Debugf("p2p.resolveRefHandler %q sending ref %v to peer %q", q.host.ID(), res.Ref, p)
+	err = sendRef(s, res)
 	if err != nil {
 		log.Debugf("p2p.ResolveRef - error sending ref to %q: %s", p, err)
 		return
 	}
 }
+
+// serveResolveRef responds to an inbound resolution request, responding with
+// a busy status if the node is already handling as many requests as it allows.
+// Nodes without a local resolver respond immediately with a no-resolver status,
+// so requesters fail fast instead of waiting out their timeout
+func (q *QriNode) serveResolveRef(ctx context.Context, req *resolveRefMessage) *resolveRefMessage {
+	if q.localResolver == nil {
+		log.Debugf("p2p.resolveRefHandler - qri node has no local resolver, rejecting request for %q", req.Ref)
+		res := &resolveRefMessage{Ref: req.Ref, Status: resolveRefStatusNoResolver}
+		res.ResponderPeerID, res.ResponderProfileID = q.responderIdentity()
+		return res
+	}
+	if !q.acquireResolveHandler() {
+		log.Debugf("p2p.resolveRefHandler - too many concurrent requests, rejecting request for %q", req.Ref)
+		return &resolveRefMessage{Ref: req.Ref, Status: resolveRefStatusBusy}
+	}
+	defer q.releaseResolveHandler()
+	res := q.resolveRefResponse(ctx, req)
+	if res.Status == resolveRefStatusFound {
+		q.served.record(req.Ref)
+	}
+	return res
+}
+
+// responderIdentity returns the peer & profile IDs this node identifies
+// itself with in responses
+func (q *QriNode) responderIdentity() (peerID, profileID string) {
+	if q.ID != "" {
+		peerID = q.ID.Pretty()
+	}
+	if q.Repo != nil {
+		if pro, err := q.Repo.Profile(); err == nil {
+			profileID = pro.ID.String()
+		}
+	}
+	return peerID, profileID
+}
+
+// acquireResolveHandler reserves a slot to handle an inbound resolution
+// request, reporting false if all slots are taken
+func (q *QriNode) acquireResolveHandler() bool {
+	if q.resolveSem == nil {
+		return true
+	}
+	select {
+	case q.resolveSem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseResolveHandler frees a slot reserved by acquireResolveHandler
+func (q *QriNode) releaseResolveHandler() {
+	if q.resolveSem != nil {
+		<-q.resolveSem
+	}
+}
+
+// resolveRefResponse resolves a requested reference locally, creating a
+// response message that includes the resolution status
+func (q *QriNode) resolveRefResponse(ctx context.Context, req *resolveRefMessage) *resolveRefMessage {
+	ref := req.Ref.Copy()
+	res := &resolveRefMessage{Status: resolveRefStatusFound}
+	res.ResponderPeerID, res.ResponderProfileID = q.responderIdentity()
+
+	if _, err := q.serveResolver().ResolveRef(ctx, &ref); err != nil {
+		log.Debugf("p2p.resolveRefHandler - error resolving ref locally: %s", err)
+		res.Status = resolveRefStatusNotFound
+	}
+	res.Ref = ref
+
+	if res.Status == resolveRefStatusFound && req.WantChecksum && ref.Path != "" {
+		var err error
+		if res.Checksum, err = q.manifestChecksum(ctx, ref.Path); err != nil {
+			log.Debugf("p2p.resolveRefHandler - error calculating manifest checksum for %q: %s", ref.Path, err)
+		}
+	}
+
+	if res.Status == resolveRefStatusFound && req.WantHead && ref.Path != "" {
+		var err error
+		if res.Head, err = q.headInfo(ctx, ref.Path); err != nil {
+			log.Debugf("p2p.resolveRefHandler - error loading head metadata for %q: %s", ref.Path, err)
+		}
+	}
+
+	return res
+}
+
+// CanLikelyResolve is a cheap check for whether a reference is likely to
+// resolve, without fanning requests out to the network. It reports true if the
+// reference resolves locally or any live, connected qri peer supports the
+// reference resolution protocol, along with a human-readable reason
+func (q *QriNode) CanLikelyResolve(ctx context.Context, ref dsref.Ref) (bool, string) {
+	if q.localResolver != nil {
+		if _, err := q.localResolver.ResolveRef(ctx, &ref); err == nil {
+			return true, "reference resolves locally"
+		}
+	}
+
+	if q.host == nil {
+		return false, "reference isn't local and node is offline"
+	}
+
+	supporting := 0
+	for _, pid := range q.LiveQriPeerIDs() {
+		protocols, err := q.host.Peerstore().SupportsProtocols(pid, string(ResolveRefProtocolID))
+		if err == nil && len(protocols) != 0 {
+			supporting++
+		}
+	}
+	if supporting == 0 {
+		return false, "reference isn't local and no connected peers support reference resolution"
+	}
+	return true, fmt.Sprintf("%d connected peers support reference resolution", supporting)
+}
@@ -1,12 +1,22 @@
 package p2p
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/libp2p/go-libp2p-core/network"
 	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/qri-io/qri/base/dsfs"
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
 	"github.com/qri-io/qri/dscache"
 	"github.com/qri-io/qri/dsref"
 	dsrefspec "github.com/qri-io/qri/dsref/spec"
@@ -15,6 +25,7 @@ import (
 	"github.com/qri-io/qri/logbook/oplog"
 	p2ptest "github.com/qri-io/qri/p2p/test"
 	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
 )
 
 func TestResolveRef(t *testing.T) {
@@ -124,7 +135,7 @@ func TestResolveRef(t *testing.T) {
 
 	p2pRefResolver := node.NewP2PRefResolver()
 
-	dsrefspec.AssertResolverSpec(t, p2pRefResolver, func(r dsref.Ref, author identity.Author, _ *oplog.Log) error {
+	dsrefspec.AssertResolverSpec(t, p2pRefResolver, func(ctx context.Context, r dsref.Ref, author identity.Author, _ *oplog.Log) error {
 		builder := dscache.NewBuilder()
 		pid, err := identity.KeyIDFromPub(author.AuthorPubKey())
 		builder.AddUser(r.Username, pid)
@@ -148,3 +159,963 @@ func TestResolveRef(t *testing.T) {
 		return nil
 	})
 }
+
+func TestResolveRefPartialResultOnTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	req := dsref.Ref{Username: "peer", Name: "dataset"}
+	resCh := make(chan resolveRefRes, 3)
+	// two peers respond with incomplete references, a third never responds
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset", ProfileID: "QmProfileID"}}
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}}
+
+	got := req.Copy()
+	_, _, err := awaitResolveRefResults(ctx, &got, resCh, 3)
+
+	partialErr := &PartialResolutionError{}
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("expected a *PartialResolutionError, got: %#v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded")
+	}
+	expect := dsref.Ref{Username: "peer", Name: "dataset", ProfileID: "QmProfileID"}
+	if !expect.Equals(partialErr.Ref) {
+		t.Errorf("partial ref mismatch. expected: %s, got: %s", expect, partialErr.Ref)
+	}
+	if !req.Equals(got) {
+		t.Errorf("ref must not be modified on error. expected: %s, got: %s", req, got)
+	}
+
+	// no peer adds information
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	resCh = make(chan resolveRefRes, 2)
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}}
+	_, _, err = awaitResolveRefResults(ctx, &got, resCh, 2)
+	if errors.As(err, &partialErr) {
+		t.Errorf("expected no partial result error when peers add no information")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func TestResolveRefChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	rr := &p2pRefResolver{
+		verifyChecksum: true,
+		checksum: func(_ context.Context, path string) (string, error) {
+			return "local_checksum", nil
+		},
+	}
+
+	ref := dsref.Ref{
+		InitID:    "init_id",
+		Username:  "peer",
+		ProfileID: "QmProfileID",
+		Name:      "dataset",
+		Path:      "/ipfs/QmeXaMpLe",
+	}
+
+	if err := rr.verifyResponseChecksum(ctx, &resolveRefMessage{Ref: ref, Checksum: "local_checksum"}); err != nil {
+		t.Errorf("expected matching checksum to verify, got: %s", err)
+	}
+	if err := rr.verifyResponseChecksum(ctx, &resolveRefMessage{Ref: ref}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected missing checksum to return ErrChecksumMismatch, got: %v", err)
+	}
+
+	err := rr.verifyResponseChecksum(ctx, &resolveRefMessage{Ref: ref, Checksum: "corrupted_checksum"})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected mismatched checksum to return ErrChecksumMismatch, got: %v", err)
+	}
+
+	// a rejected response fails resolution with the checksum error
+	resCh := make(chan resolveRefRes, 2)
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}, err: err}
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}}
+
+	got := dsref.Ref{Username: "peer", Name: "dataset"}
+	if _, _, err := awaitResolveRefResults(ctx, &got, resCh, 2); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected resolution to fail with ErrChecksumMismatch, got: %v", err)
+	}
+}
+
+func TestResolveRefMinAgreement(t *testing.T) {
+	ctx := context.Background()
+	agreed := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmAgreed"}
+	disagree := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmDisagree"}
+
+	rr := (&QriNode{}).NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.MinAgreement = 2
+	}).(*p2pRefResolver)
+
+	// the disagreeing peer responds first, and must not win
+	resCh := make(chan resolveRefRes, 3)
+	resCh <- resolveRefRes{ref: &disagree, source: "peer_c", found: true}
+	resCh <- resolveRefRes{ref: &agreed, source: "peer_a", found: true}
+	resCh <- resolveRefRes{ref: &agreed, source: "peer_b", found: true}
+
+	got := dsref.Ref{Username: "peer", Name: "dataset"}
+	source, _, err := rr.await(ctx, &got, resCh, 3)
+	if err != nil {
+		t.Fatalf("expected agreement, got: %s", err)
+	}
+	if !agreed.Equals(got) {
+		t.Errorf("result mismatch. expected: %s, got: %s", agreed, got)
+	}
+	if source != "peer_a" {
+		t.Errorf("source mismatch. expected: %q, got: %q", "peer_a", source)
+	}
+
+	// peers answer without reaching agreement
+	resCh = make(chan resolveRefRes, 3)
+	resCh <- resolveRefRes{ref: &disagree, source: "peer_c", found: true}
+	resCh <- resolveRefRes{ref: &agreed, source: "peer_a", found: true}
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}, source: "peer_b"}
+
+	req := dsref.Ref{Username: "peer", Name: "dataset"}
+	got = req.Copy()
+	_, _, err = rr.await(ctx, &got, resCh, 3)
+	if !errors.Is(err, ErrNoAgreement) {
+		t.Fatalf("expected ErrNoAgreement, got: %v", err)
+	}
+	agreementErr := &AgreementError{}
+	if !errors.As(err, &agreementErr) {
+		t.Fatalf("expected an *AgreementError, got: %#v", err)
+	}
+	if len(agreementErr.Candidates) != 2 {
+		t.Errorf("expected disagreeing answers to be surfaced as 2 candidates, got: %d", len(agreementErr.Candidates))
+	}
+	if !req.Equals(got) {
+		t.Errorf("ref must not be modified on error. expected: %s, got: %s", req, got)
+	}
+
+	// no peer finds the reference
+	resCh = make(chan resolveRefRes, 1)
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}, source: "peer_a"}
+	if _, _, err = rr.await(ctx, &got, resCh, 1); !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Errorf("expected ErrRefNotFound, got: %v", err)
+	}
+}
+
+func TestResolveRefMessageCompatibility(t *testing.T) {
+	ref := dsref.Ref{Username: "peer", Name: "dataset", Path: "/ipfs/QmeXaMpLe"}
+	s := &bufferStream{buf: &bytes.Buffer{}}
+	if err := sendRef(s, &resolveRefMessage{Ref: ref, Checksum: "checksum"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// peers that only know about bare references must be able to read messages
+	got := dsref.Ref{}
+	if err := WrapStream(s).dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !ref.Equals(got) {
+		t.Errorf("result mismatch. expected: %s, got: %s", ref, got)
+	}
+}
+
+func TestCanLikelyResolve(t *testing.T) {
+	ctx := context.Background()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	local := dsref.StaticResolver(map[string]dsref.Ref{
+		"peer/local": {InitID: "init_id", Username: "peer", Name: "local", Path: "/ipfs/QmLocal"},
+	})
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, local)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	if ok, reason := n.CanLikelyResolve(ctx, dsref.Ref{Username: "peer", Name: "local"}); !ok {
+		t.Errorf("expected local reference to be resolvable. reason: %s", reason)
+	}
+
+	if err := n.GoOnline(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer n.GoOffline()
+
+	missing := dsref.Ref{Username: "peer", Name: "missing"}
+	ok, reason := n.CanLikelyResolve(ctx, missing)
+	if ok {
+		t.Errorf("expected reference with no resolver peers to be unresolvable")
+	}
+	expect := "reference isn't local and no connected peers support reference resolution"
+	if reason != expect {
+		t.Errorf("reason mismatch. expected: %q, got: %q", expect, reason)
+	}
+
+	// pretend a connected qri peer supports reference resolution
+	pid := cfgtest.GetTestPeerInfo(1).PeerID
+	markQriPeer(t, n, pid)
+	if err := n.host.Peerstore().AddProtocols(pid, string(ResolveRefProtocolID)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason := n.CanLikelyResolve(ctx, missing); !ok {
+		t.Errorf("expected reference to be likely resolvable with a supporting peer. reason: %s", reason)
+	}
+}
+
+func TestResolveRefAll(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	a := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmA"}
+	b := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmB"}
+
+	resCh := make(chan resolveRefRes, 5)
+	// two peers return different paths, one duplicates a path, one is
+	// incomplete, and one never responds
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}}
+	resCh <- resolveRefRes{ref: &a, source: "peer_a", pid: "peer_a", profileID: "QmPeerAProfile", found: true}
+	resCh <- resolveRefRes{ref: &b, source: "peer_b", found: true}
+	resCh <- resolveRefRes{ref: &dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmA"}, source: "peer_c", found: true}
+
+	got, err := collectResolveRefCandidates(ctx, resCh, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []RefCandidate{{Ref: a, Source: "peer_a", PeerID: "peer_a", ProfileID: "QmPeerAProfile"}, {Ref: b, Source: "peer_b"}}
+	if len(got) != len(expect) {
+		t.Fatalf("candidate length mismatch. expected: %d, got: %d (%v)", len(expect), len(got), got)
+	}
+	for i, c := range expect {
+		if !c.Ref.Equals(got[i].Ref) || c.Source != got[i].Source || c.PeerID != got[i].PeerID || c.ProfileID != got[i].ProfileID {
+			t.Errorf("candidate %d mismatch. expected: %v, got: %v", i, c, got[i])
+		}
+	}
+
+	// no complete responses
+	resCh = make(chan resolveRefRes, 1)
+	resCh <- resolveRefRes{ref: &dsref.Ref{Username: "peer", Name: "dataset"}}
+	if _, err := collectResolveRefCandidates(context.Background(), resCh, 1); err != dsref.ErrRefNotFound {
+		t.Errorf("expected ErrRefNotFound, got: %v", err)
+	}
+}
+
+func TestResolveRefNotFoundStatus(t *testing.T) {
+	ctx := context.Background()
+	n := &QriNode{localResolver: dsref.NoopResolver()}
+
+	// the handler can't resolve the reference, which is sent to the client
+	req := &resolveRefMessage{Ref: dsref.Ref{Username: "peer", Name: "dataset"}}
+	s := &bufferStream{buf: &bytes.Buffer{}}
+	if err := sendRef(s, n.resolveRefResponse(ctx, req)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := receiveRef(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != resolveRefStatusNotFound {
+		t.Errorf("status mismatch. expected: %q, got: %q", resolveRefStatusNotFound, res.Status)
+	}
+	if res.found() {
+		t.Errorf("expected client to see response as not found")
+	}
+
+	// an explicit not-found status is respected even if the reference is complete
+	complete := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmeXaMpLe"}
+	if (&resolveRefMessage{Ref: complete, Status: resolveRefStatusNotFound}).found() {
+		t.Errorf("expected not-found status to take precedence over a complete reference")
+	}
+
+	// responses from peers that predate statuses fall back to reference completeness
+	if !(&resolveRefMessage{Ref: complete}).found() {
+		t.Errorf("expected complete reference with no status to be found")
+	}
+	if (&resolveRefMessage{Ref: req.Ref}).found() {
+		t.Errorf("expected incomplete reference with no status to be not found")
+	}
+
+	n.localResolver = dsref.StaticResolver(map[string]dsref.Ref{"peer/dataset": complete})
+	if res := n.resolveRefResponse(ctx, req); res.Status != resolveRefStatusFound || !complete.Equals(res.Ref) {
+		t.Errorf("expected found response with resolved ref %s, got: %q %s", complete, res.Status, res.Ref)
+	}
+}
+
+func TestResolveRefHead(t *testing.T) {
+	ctx := context.Background()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, 0)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, r)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	ref := dsref.Ref{Username: "test-repo-0", Name: "movies"}
+	if res := n.resolveRefResponse(ctx, &resolveRefMessage{Ref: ref}); res.Head != nil {
+		t.Errorf("expected no head metadata when none is requested, got: %#v", res.Head)
+	}
+
+	// send the response over a stream, the way a requesting peer receives it
+	s := &bufferStream{buf: &bytes.Buffer{}}
+	if err := sendRef(s, n.resolveRefResponse(ctx, &resolveRefMessage{Ref: ref, WantHead: true})); err != nil {
+		t.Fatal(err)
+	}
+	res, err := receiveRef(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.found() {
+		t.Fatalf("expected reference to resolve")
+	}
+
+	ds, err := dsfs.LoadDataset(ctx, r.Store(), res.Ref.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &dsref.HeadInfo{
+		CommitTitle: ds.Commit.Title,
+		CommitTime:  ds.Commit.Timestamp,
+		BodySize:    ds.Structure.Length,
+	}
+	if diff := cmp.Diff(expect, res.Head); diff != "" {
+		t.Errorf("head metadata mismatch (-want +got):\n%s", diff)
+	}
+
+	// head metadata from the winning response is returned to the caller
+	resCh := make(chan resolveRefRes, 1)
+	resCh <- resolveRefRes{ref: &res.Ref, source: "peer", head: res.Head, found: true}
+	got := ref.Copy()
+	_, head, err := awaitResolveRefResults(ctx, &got, resCh, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect, head); diff != "" {
+		t.Errorf("returned head metadata mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolveRefSkipsSelf(t *testing.T) {
+	ctx := context.Background()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, dsref.NoopResolver())
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+	if err := n.GoOnline(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer n.GoOffline()
+
+	// inject the node's own ID into the connected set
+	markQriPeer(t, n, n.host.ID())
+
+	rr := n.NewP2PRefResolver().(*p2pRefResolver)
+	if pids := rr.fanOutPeerIDs(dsref.Ref{}); len(pids) != 0 {
+		t.Errorf("expected own peer ID to be excluded from fan-out, got: %v", pids)
+	}
+
+	trace := &dsref.Trace{}
+	if _, err := rr.ResolveRef(dsref.WithTrace(ctx, trace), &dsref.Ref{Username: "peer", Name: "dataset"}); err != dsref.ErrRefNotFound {
+		t.Errorf("expected ErrRefNotFound, got: %v", err)
+	}
+	if spans := trace.Spans(); len(spans) != 0 {
+		t.Errorf("expected no peer requests, got: %v", spans)
+	}
+}
+
+func TestResolveRefHandlerRespondsToSelf(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, dsref.NoopResolver())
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+	if err := n.GoOnline(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer n.GoOffline()
+
+	// a request that arrives from the node's own ID gets an immediate
+	// not-found response instead of leaving the requester to time out
+	req, handler := newPipeStreams(n.host.ID())
+	go n.resolveRefHandler(handler)
+	ref := dsref.Ref{Username: "peer", Name: "dataset"}
+	if err := sendRef(req, &resolveRefMessage{Ref: ref}); err != nil {
+		t.Fatal(err)
+	}
+
+	resCh := make(chan *resolveRefMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		res, err := receiveRef(req)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		resCh <- res
+	}()
+
+	select {
+	case res := <-resCh:
+		if res.Status != resolveRefStatusNotFound {
+			t.Errorf("expected status %q, got: %q", resolveRefStatusNotFound, res.Status)
+		}
+		if diff := cmp.Diff(ref, res.Ref); diff != "" {
+			t.Errorf("response ref mismatch (-want +got):\n%s", diff)
+		}
+	case err := <-errCh:
+		t.Fatalf("reading response: %s", err)
+	case <-time.After(time.Second):
+		t.Fatal("expected self request to get a response immediately")
+	}
+	req.Close()
+}
+
+// pipeStream is an in-memory network.Stream to a remote peer. Closing a
+// pipeStream closes its write side, like closing a libp2p stream
+type pipeStream struct {
+	network.Stream
+	r      *io.PipeReader
+	w      *io.PipeWriter
+	remote peer.ID
+}
+
+// newPipeStreams creates a connected pair of streams, both from the remote
+// peer's point of view
+func newPipeStreams(remote peer.ID) (a, b *pipeStream) {
+	ar, bw := io.Pipe()
+	br, aw := io.Pipe()
+	return &pipeStream{r: ar, w: aw, remote: remote}, &pipeStream{r: br, w: bw, remote: remote}
+}
+
+func (s *pipeStream) Read(p []byte) (int, error)      { return s.r.Read(p) }
+func (s *pipeStream) Write(p []byte) (int, error)     { return s.w.Write(p) }
+func (s *pipeStream) Close() error                    { return s.w.Close() }
+func (s *pipeStream) SetDeadline(time.Time) error     { return nil }
+func (s *pipeStream) SetReadDeadline(time.Time) error { return nil }
+func (s *pipeStream) Conn() network.Conn              { return pipeConn{remote: s.remote} }
+func (s *pipeStream) Reset() error {
+	s.r.Close()
+	return s.w.Close()
+}
+
+// pipeConn is the connection of a pipeStream
+type pipeConn struct {
+	network.Conn
+	remote peer.ID
+}
+
+func (c pipeConn) RemotePeer() peer.ID           { return c.remote }
+func (c pipeConn) RemoteMultiaddr() ma.Multiaddr { return nil }
+
+func TestResolveRefDiscoversLocalPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 2)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+
+	// only trust the second node, so stray peers found by other tests in this
+	// process can't answer the request
+	trusted := TrustedPeers(nodes[1].host.ID())
+	settleTestPeer(ctx, t, nodes[0], nodes[1])
+
+	// without discovery a node with no connected peers can't resolve anything
+	ref := &dsref.Ref{Username: "test-repo-1", Name: "cities"}
+	noDiscovery := nodes[0].NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.PeerSelector = trusted
+	})
+	if _, err := noDiscovery.ResolveRef(ctx, ref); !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Fatalf("expected ErrRefNotFound without discovery, got: %v", err)
+	}
+
+	rr := nodes[0].NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.PeerSelector = trusted
+		o.DiscoverLocalPeers = true
+	}).(*p2pRefResolver)
+	if rr.discover == nil {
+		t.Fatal("expected DiscoverLocalPeers option to enable discovery")
+	}
+	// stub mDNS, "discovering" the second node
+	discoverCalls := 0
+	rr.discover = func(context.Context) ([]peer.AddrInfo, error) {
+		discoverCalls++
+		return []peer.AddrInfo{nodes[1].SimpleAddrInfo()}, nil
+	}
+
+	ref = &dsref.Ref{Username: "test-repo-1", Name: "cities"}
+	if _, err := rr.ResolveRef(ctx, ref); err != nil {
+		t.Fatalf("resolving with discovery: %s", err)
+	}
+	if ref.Path == "" {
+		t.Errorf("expected resolved ref to have a path")
+	}
+	if discoverCalls != 1 {
+		t.Errorf("expected discovery to run once, got: %d", discoverCalls)
+	}
+
+	// once connected, resolution no longer needs discovery
+	ref = &dsref.Ref{Username: "test-repo-1", Name: "cities"}
+	if _, err := rr.ResolveRef(ctx, ref); err != nil {
+		t.Fatalf("resolving with a connected peer: %s", err)
+	}
+	if discoverCalls != 1 {
+		t.Errorf("expected connected peers to skip discovery, got %d discovery calls", discoverCalls)
+	}
+}
+
+func TestResolveRefAttempts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 2)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+
+	settleTestPeer(ctx, t, nodes[0], nodes[1])
+
+	// with no connected peers, resolution falls back to discovering the
+	// second node
+	rr := nodes[0].NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.PeerSelector = TrustedPeers(nodes[1].host.ID())
+		o.DiscoverLocalPeers = true
+	}).(*p2pRefResolver)
+	rr.discover = func(context.Context) ([]peer.AddrInfo, error) {
+		return []peer.AddrInfo{nodes[1].SimpleAddrInfo()}, nil
+	}
+
+	attempts := &dsref.Attempts{}
+	ref := &dsref.Ref{Username: "test-repo-1", Name: "cities"}
+	if _, err := rr.ResolveRef(dsref.WithAttempts(ctx, attempts), ref); err != nil {
+		t.Fatal(err)
+	}
+
+	got := attempts.List()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 attempts, got: %v", got)
+	}
+	if got[0].Stage != ResolveStageConnectedPeers || got[0].Requests != 0 || got[0].Found || !errors.Is(got[0].Err, dsref.ErrRefNotFound) {
+		t.Errorf("expected first attempt to fail with no connected peers to ask, got: %+v", got[0])
+	}
+	if got[1].Stage != ResolveStageLocalDiscovery || got[1].Requests != 1 || !got[1].Found || got[1].Err != nil {
+		t.Errorf("expected second attempt to resolve via discovery, got: %+v", got[1])
+	}
+	if got[1].Duration <= 0 {
+		t.Errorf("expected positive attempt duration, got: %s", got[1].Duration)
+	}
+
+	// resolving without attempts attached to the context records nothing
+	ref = &dsref.Ref{Username: "test-repo-1", Name: "cities"}
+	if _, err := rr.ResolveRef(ctx, ref); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts.List()) != 2 {
+		t.Errorf("expected resolution without attempts in context not to record")
+	}
+}
+
+func TestResolveRefStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 3)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+	connectTestPeer(ctx, t, nodes[0], nodes[1])
+	connectTestPeer(ctx, t, nodes[0], nodes[2])
+
+	rr := nodes[0].NewP2PRefResolver(func(o *P2PRefResolverOptions) {
+		o.PeerSelector = TrustedPeers(nodes[1].host.ID(), nodes[2].host.ID())
+	}).(*p2pRefResolver)
+
+	// neither peer has the dataset, so resolution waits for both to respond
+	var (
+		lk        sync.Mutex
+		responses = map[peer.ID]PeerResolveResult{}
+		returned  bool
+	)
+	ref := &dsref.Ref{Username: "test-repo-1", Name: "not_a_dataset"}
+	_, err = rr.ResolveRefStream(ctx, ref, func(res PeerResolveResult) {
+		lk.Lock()
+		defer lk.Unlock()
+		if returned {
+			t.Errorf("onResponse called after ResolveRefStream returned")
+		}
+		if _, ok := responses[res.PeerID]; ok {
+			t.Errorf("peer %s responded more than once", res.PeerID)
+		}
+		responses[res.PeerID] = res
+	})
+	lk.Lock()
+	returned = true
+	defer lk.Unlock()
+
+	if !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Errorf("expected ErrRefNotFound, got: %v", err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("expected a response from each of 2 peers, got: %d", len(responses))
+	}
+	for _, node := range nodes[1:] {
+		res, ok := responses[node.host.ID()]
+		if !ok {
+			t.Errorf("expected a response from peer %s", node.host.ID())
+			continue
+		}
+		if res.Found {
+			t.Errorf("expected peer %s not to find the reference", node.host.ID())
+		}
+	}
+}
+
+func TestResolveRefDebug(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 2)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+
+	connectTestPeer(ctx, t, nodes[0], nodes[1])
+	nodes[0].connectQriPeers(ctx, []peer.AddrInfo{nodes[1].SimpleAddrInfo()})
+	// add a peer with no known addresses, requests to it fail
+	unreachable := cfgtest.GetTestPeerInfo(9).PeerID
+	markQriPeer(t, nodes[0], unreachable)
+
+	results, err := nodes[0].ResolveRefDebug(ctx, dsref.Ref{Username: "test-repo-1", Name: "cities"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other tests may leave peers running in this process, only check peers
+	// this test controls
+	byPeer := map[peer.ID]PeerResolveResult{}
+	for _, res := range results {
+		byPeer[res.PeerID] = res
+	}
+
+	res, ok := byPeer[nodes[1].host.ID()]
+	if !ok {
+		t.Fatalf("expected result for peer holding the reference, got: %v", results)
+	}
+	if !res.Found || res.Err != nil {
+		t.Errorf("expected peer holding the reference to resolve it. found: %t, err: %v", res.Found, res.Err)
+	}
+	if res.Ref.Path == "" {
+		t.Errorf("expected resolved reference to have a path")
+	}
+	if res.Latency <= 0 {
+		t.Errorf("expected positive latency, got: %s", res.Latency)
+	}
+	pro, err := nodes[1].Repo.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ProfileID != pro.ID.String() {
+		t.Errorf("expected result to identify the responder's profile %q, got: %q", pro.ID, res.ProfileID)
+	}
+
+	res, ok = byPeer[unreachable]
+	if !ok {
+		t.Fatalf("expected result for unreachable peer, got: %v", results)
+	}
+	if res.Found || res.Err == nil {
+		t.Errorf("expected unreachable peer to fail with an error. found: %t, err: %v", res.Found, res.Err)
+	}
+	if res.Latency <= 0 {
+		t.Errorf("expected positive latency, got: %s", res.Latency)
+	}
+
+	// peers that don't respond before the timeout are reported with the
+	// context error
+	pids := []peer.ID{"peer_a", "peer_b"}
+	ref := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmA"}
+	resCh := make(chan resolveRefRes, 1)
+	resCh <- resolveRefRes{pid: "peer_b", ref: &ref, source: "peer_b", found: true, latency: time.Millisecond}
+	timeout, cancelTimeout := context.WithTimeout(ctx, time.Millisecond*20)
+	defer cancelTimeout()
+	got := collectPeerResolveResults(timeout, pids, time.Now(), dsref.Ref{Username: "peer", Name: "dataset"}, resCh)
+	if len(got) != 2 {
+		t.Fatalf("expected a result for each peer, got: %v", got)
+	}
+	if got[0].PeerID != "peer_a" || !errors.Is(got[0].Err, context.DeadlineExceeded) {
+		t.Errorf("expected unresponsive peer to report a deadline error, got: %v", got[0])
+	}
+	if got[1].PeerID != "peer_b" || !got[1].Found || got[1].Latency != time.Millisecond {
+		t.Errorf("expected responsive peer result, got: %v", got[1])
+	}
+}
+
+// blockingResolver tracks concurrent calls, blocking each call until release
+// is closed
+type blockingResolver struct {
+	release chan struct{}
+
+	lk          sync.Mutex
+	active, max int
+}
+
+func (br *blockingResolver) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
+	br.lk.Lock()
+	br.active++
+	if br.active > br.max {
+		br.max = br.active
+	}
+	br.lk.Unlock()
+
+	select {
+	case <-br.release:
+	case <-ctx.Done():
+	}
+
+	br.lk.Lock()
+	br.active--
+	br.lk.Unlock()
+	return "", dsref.ErrRefNotFound
+}
+
+func TestResolveRefHandlerConcurrencyLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	cfg := config.DefaultP2PForTesting()
+	cfg.MaxResolveHandlers = 3
+	n, err := NewQriNode(r, cfg, event.NilBus, dsref.NoopResolver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cap(n.resolveSem) != 3 {
+		t.Errorf("expected configured handler limit of 3, got: %d", cap(n.resolveSem))
+	}
+
+	const limit, numReqs = 2, 8
+	resolver := &blockingResolver{release: make(chan struct{})}
+	n = &QriNode{localResolver: resolver, resolveSem: make(chan struct{}, limit)}
+
+	results := make(chan *resolveRefMessage, numReqs)
+	for i := 0; i < numReqs; i++ {
+		go func() {
+			results <- n.serveResolveRef(ctx, &resolveRefMessage{Ref: dsref.Ref{Username: "peer", Name: "dataset"}})
+		}()
+	}
+
+	// requests beyond the limit are rejected while the first requests block
+	for i := 0; i < numReqs-limit; i++ {
+		if res := <-results; res.Status != resolveRefStatusBusy {
+			t.Errorf("expected request beyond the limit to get a busy response, got: %q", res.Status)
+		}
+	}
+	close(resolver.release)
+	for i := 0; i < limit; i++ {
+		if res := <-results; res.Status != resolveRefStatusNotFound {
+			t.Errorf("expected handled request to get a not-found response, got: %q", res.Status)
+		}
+	}
+
+	resolver.lk.Lock()
+	if resolver.max != limit {
+		t.Errorf("expected concurrent handlers to reach but not exceed the limit of %d, got: %d", limit, resolver.max)
+	}
+	resolver.lk.Unlock()
+
+	// handler slots are freed once requests complete
+	if res := n.serveResolveRef(ctx, &resolveRefMessage{Ref: dsref.Ref{Username: "peer", Name: "dataset"}}); res.Status == resolveRefStatusBusy {
+		t.Errorf("expected request after others complete not to be busy")
+	}
+
+	// busy responses aren't resolutions
+	complete := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmeXaMpLe"}
+	if (&resolveRefMessage{Ref: complete, Status: resolveRefStatusBusy}).found() {
+		t.Errorf("expected busy response not to be found")
+	}
+}
+
+func TestResolveRefNoLocalResolver(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestNetwork(ctx, factory, 2)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	nodes := asQriNodes(testPeers)
+	for _, node := range nodes {
+		node.Discovery.Close()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.GoOffline()
+		}
+	}()
+
+	// the second node doesn't serve resolution
+	nodes[1].localResolver = nil
+	connectTestPeer(ctx, t, nodes[0], nodes[1])
+	nodes[0].connectQriPeers(ctx, []peer.AddrInfo{nodes[1].SimpleAddrInfo()})
+
+	rr := &p2pRefResolver{node: nodes[0]}
+	ref := &dsref.Ref{Username: "test-repo-1", Name: "cities"}
+	start := time.Now()
+	_, _, _, err = rr.resolveRefRequest(ctx, nodes[1].host.ID(), ref, false)
+	if !errors.Is(err, ErrNoResolver) {
+		t.Errorf("expected ErrNoResolver, got: %v", err)
+	}
+	// without a response the request would wait for the test context to expire
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Errorf("expected request to fail fast, took %s", elapsed)
+	}
+
+	res := nodes[1].serveResolveRef(ctx, &resolveRefMessage{Ref: *ref})
+	if res.found() {
+		t.Errorf("expected no-resolver response not to be found")
+	}
+}
+
+func TestResolveRefResponderIdentity(t *testing.T) {
+	ctx := context.Background()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, 0)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, r)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	// responses carry the responder's identity, found or not
+	for _, ref := range []dsref.Ref{
+		{Username: "test-repo-0", Name: "movies"},
+		{Username: "test-repo-0", Name: "missing"},
+	} {
+		res := n.resolveRefResponse(ctx, &resolveRefMessage{Ref: ref})
+		if res.ResponderPeerID != info.PeerID.Pretty() {
+			t.Errorf("%s: responder peer ID mismatch. expected: %q, got: %q", ref, info.PeerID.Pretty(), res.ResponderPeerID)
+		}
+		if expect := profile.IDFromPeerID(info.PeerID).String(); res.ResponderProfileID != expect {
+			t.Errorf("%s: responder profile ID mismatch. expected: %q, got: %q", ref, expect, res.ResponderProfileID)
+		}
+	}
+
+	// responder identity survives the wire, without clobbering the dataset
+	// author's profile ID
+	res := n.resolveRefResponse(ctx, &resolveRefMessage{Ref: dsref.Ref{Username: "test-repo-0", Name: "movies"}})
+	res.Ref.ProfileID = "QmDatasetAuthor"
+	s := &bufferStream{buf: &bytes.Buffer{}}
+	if err := sendRef(s, res); err != nil {
+		t.Fatal(err)
+	}
+	got, err := receiveRef(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ResponderPeerID != res.ResponderPeerID || got.Ref.ProfileID != "QmDatasetAuthor" {
+		t.Errorf("expected responder identity & dataset profile ID to round trip, got: %q, %q", got.ResponderPeerID, got.Ref.ProfileID)
+	}
+
+	// the client surfaces the responder as the source along with its profile,
+	// rejecting responses from a different peer than was asked
+	source, profileID, err := n.responder(info.PeerID, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != info.PeerID.Pretty() {
+		t.Errorf("source mismatch. expected: %q, got: %q", info.PeerID.Pretty(), source)
+	}
+	if profileID != got.ResponderProfileID {
+		t.Errorf("profile ID mismatch. expected: %q, got: %q", got.ResponderProfileID, profileID)
+	}
+	other := cfgtest.GetTestPeerInfo(1).PeerID
+	if _, _, err := n.responder(other, got); !errors.Is(err, ErrResponderMismatch) {
+		t.Errorf("expected response from unexpected peer to return ErrResponderMismatch, got: %v", err)
+	}
+	// peers that predate responder identity are identified by the stream
+	if source, profileID, err := n.responder(other, &resolveRefMessage{}); err != nil || source != other.Pretty() || profileID != "" {
+		t.Errorf("expected legacy response source to be the requested peer. got: %q, %q, %v", source, profileID, err)
+	}
+
+	// a peer known by one profile can't claim another
+	known := &profile.Profile{ID: profile.IDFromPeerID(other), Peername: "other", PeerIDs: []peer.ID{other}}
+	if err := r.Profiles().PutProfile(known); err != nil {
+		t.Fatal(err)
+	}
+	claim := &resolveRefMessage{ResponderPeerID: other.Pretty(), ResponderProfileID: profile.IDFromPeerID(info.PeerID).String()}
+	if _, _, err := n.responder(other, claim); !errors.Is(err, ErrResponderMismatch) {
+		t.Errorf("expected a claimed profile that differs from the peer's known profile to return ErrResponderMismatch, got: %v", err)
+	}
+	claim.ResponderProfileID = known.ID.String()
+	if _, profileID, err := n.responder(other, claim); err != nil || profileID != known.ID.String() {
+		t.Errorf("expected claimed profile matching the known profile to be accepted. got: %q, %v", profileID, err)
+	}
+	// legacy responses from known peers are identified by the known profile
+	if _, profileID, err := n.responder(other, &resolveRefMessage{}); err != nil || profileID != known.ID.String() {
+		t.Errorf("expected legacy response from a known peer to use its known profile. got: %q, %v", profileID, err)
+	}
+}
@@ -0,0 +1,139 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/logbook"
+)
+
+// ServeStats summarizes the reference resolution requests this node has
+// handled for peers
+type ServeStats struct {
+	// Cache reports the effectiveness of the served-ref cache. Counts are zero
+	// if the node doesn't cache served references
+	Cache dsref.CacheStats
+	// TopRefs lists the most requested references, most requested first
+	TopRefs []RefRequestCount
+}
+
+// RefRequestCount is the number of times peers requested a reference
+type RefRequestCount struct {
+	Ref   string
+	Count int
+}
+
+const (
+	// servedRefStatsCapacity is the number of distinct references a node counts
+	// requests for
+	servedRefStatsCapacity = 1024
+	// servedRefCacheMaxEntries is the default number of references the
+	// served-ref cache holds
+	servedRefCacheMaxEntries = 1024
+)
+
+// servedRefStats counts resolved inbound requests by requested reference.
+// Counts are kept for a fixed number of references using the space-saving
+// algorithm: once full, a newly requested reference replaces the least
+// requested one, inheriting its count. Frequently requested references are
+// always kept, but counts for references that replaced another can be
+// overestimates
+type servedRefStats struct {
+	lk       sync.Mutex
+	capacity int
+	counts   map[string]int
+}
+
+func newServedRefStats(capacity int) *servedRefStats {
+	return &servedRefStats{capacity: capacity, counts: map[string]int{}}
+}
+
+// record adds a request for a reference
+func (s *servedRefStats) record(ref dsref.Ref) {
+	if s == nil {
+		return
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	key := ref.String()
+	if _, ok := s.counts[key]; ok || len(s.counts) < s.capacity {
+		s.counts[key]++
+		return
+	}
+
+	var (
+		minKey   string
+		minCount int
+	)
+	for k, count := range s.counts {
+		if minKey == "" || count < minCount || (count == minCount && k < minKey) {
+			minKey, minCount = k, count
+		}
+	}
+	delete(s.counts, minKey)
+	s.counts[key] = minCount + 1
+}
+
+// top returns the n most requested references. Equal counts are ordered by
+// reference string
+func (s *servedRefStats) top(n int) []RefRequestCount {
+	if s == nil {
+		return nil
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	counts := make([]RefRequestCount, 0, len(s.counts))
+	for ref, count := range s.counts {
+		counts = append(counts, RefRequestCount{Ref: ref, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Ref < counts[j].Ref
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	if n >= 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+// CacheServedRefs caches the node's answers to peer resolution requests,
+// wrapping the local resolver with a read-through dsref.CacheResolver used only
+// to serve peers. Repeated requests for the same reference within the cache
+// TTL are answered without resolving locally. Cached heads are dropped when
+// the head of a dataset changes in the node's repo. Peers choose what to
+// request, so the cache holds at most servedRefCacheMaxEntries references
+// unless opts set a different bound. Call CacheServedRefs before the node goes
+// online
+func (q *QriNode) CacheServedRefs(opts ...func(o *dsref.CacheResolverOptions)) {
+	if q.localResolver == nil {
+		return
+	}
+	bounded := func(o *dsref.CacheResolverOptions) {
+		o.MaxEntries = servedRefCacheMaxEntries
+	}
+	q.serveCache = dsref.NewCacheResolver(q.localResolver, append([]func(o *dsref.CacheResolverOptions){bounded}, opts...)...)
+	if q.Repo != nil && q.Repo.Bus() != nil {
+		logbook.SubscribeHeadCache(q.Repo.Bus(), q.serveCache)
+	}
+}
+
+// ServeStats returns statistics on resolution requests this node has handled
+// for peers, listing up to n of the most requested references. A negative n
+// lists all counted references. Only requests this node resolved are counted
+func (q *QriNode) ServeStats(n int) ServeStats {
+	return ServeStats{
+		Cache:   q.serveCache.Stats(),
+		TopRefs: q.served.top(n),
+	}
+}
+
+// serveResolver returns the resolver to answer peer requests with
+func (q *QriNode) serveResolver() dsref.Resolver {
+	if q.serveCache != nil {
+		return q.serveCache
+	}
+	return q.localResolver
+}
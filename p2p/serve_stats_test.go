@@ -0,0 +1,143 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+)
+
+func TestServeStats(t *testing.T) {
+	ctx := context.Background()
+
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.IDFromPeerID(info.PeerID), 0, 0)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, r)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	// without a served-ref cache, cache counts stay at zero
+	movies := dsref.Ref{Username: "test-repo-0", Name: "movies"}
+	if res := n.serveResolveRef(ctx, &resolveRefMessage{Ref: movies}); res.Status != resolveRefStatusFound {
+		t.Fatalf("expected request to be found, got status: %q", res.Status)
+	}
+	if stats := n.ServeStats(-1); stats.Cache.Hits != 0 || stats.Cache.Misses != 0 {
+		t.Errorf("expected no cache counts without a cache, got: %+v", stats.Cache)
+	}
+
+	n.CacheServedRefs()
+	for i := 0; i < 3; i++ {
+		res := n.serveResolveRef(ctx, &resolveRefMessage{Ref: movies})
+		if res.Status != resolveRefStatusFound {
+			t.Fatalf("request %d: expected found, got status: %q", i, res.Status)
+		}
+		// the first request misses the cache, later requests hit
+		if stats := n.ServeStats(-1); stats.Cache.Hits != i || stats.Cache.Misses != 1 {
+			t.Errorf("request %d: expected %d hits & 1 miss, got: %+v", i, i, stats.Cache)
+		}
+	}
+	n.serveResolveRef(ctx, &resolveRefMessage{Ref: dsref.Ref{Username: "test-repo-0", Name: "missing"}})
+
+	stats := n.ServeStats(1)
+	if len(stats.TopRefs) != 1 {
+		t.Fatalf("expected top refs to be limited to 1, got: %d", len(stats.TopRefs))
+	}
+	if expect := (RefRequestCount{Ref: movies.String(), Count: 4}); stats.TopRefs[0] != expect {
+		t.Errorf("top ref mismatch. expected: %+v, got: %+v", expect, stats.TopRefs[0])
+	}
+	// requests that don't resolve aren't counted
+	if got := len(n.ServeStats(-1).TopRefs); got != 1 {
+		t.Errorf("expected 1 counted ref, got: %d", got)
+	}
+}
+
+func TestServedRefStatsCapacity(t *testing.T) {
+	s := newServedRefStats(2)
+	for _, name := range []string{"a", "a", "a", "b", "c", "c"} {
+		s.record(dsref.Ref{Username: "peer", Name: name})
+	}
+
+	// c replaces the least requested ref b, inheriting its count
+	got := s.top(-1)
+	expect := []RefRequestCount{{Ref: "peer/a", Count: 3}, {Ref: "peer/c", Count: 3}}
+	if len(got) != len(expect) {
+		t.Fatalf("expected %d counted refs, got: %v", len(expect), got)
+	}
+	for i, e := range expect {
+		if got[i] != e {
+			t.Errorf("index %d mismatch. expected: %+v, got: %+v", i, e, got[i])
+		}
+	}
+}
+
+// countingResolver counts calls to an underlying resolver
+type countingResolver struct {
+	dsref.Resolver
+	lk    sync.Mutex
+	calls int
+}
+
+func (cr *countingResolver) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
+	cr.lk.Lock()
+	cr.calls++
+	cr.lk.Unlock()
+	return cr.Resolver.ResolveRef(ctx, ref)
+}
+
+func (cr *countingResolver) Calls() int {
+	cr.lk.Lock()
+	defer cr.lk.Unlock()
+	return cr.calls
+}
+
+func TestCacheServedRefs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := event.NewBus(ctx)
+	r, err := test.NewEmptyTestRepo(bus)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	movies := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "movies", Path: "/ipfs/QmHead"}
+	local := &countingResolver{Resolver: dsref.StaticResolver(map[string]dsref.Ref{"peer/movies": movies})}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting(), event.NilBus, local)
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+	n.CacheServedRefs(func(o *dsref.CacheResolverOptions) {
+		o.TTL = time.Minute
+	})
+
+	req := dsref.Ref{Username: "peer", Name: "movies"}
+	for i := 0; i < 5; i++ {
+		if res := n.serveResolveRef(ctx, &resolveRefMessage{Ref: req}); res.Status != resolveRefStatusFound {
+			t.Fatalf("request %d: expected found, got status: %q", i, res.Status)
+		}
+	}
+	if calls := local.Calls(); calls != 1 {
+		t.Errorf("expected repeated requests to resolve locally once, resolved %d times", calls)
+	}
+
+	// a head change drops the cached answer
+	if err := bus.Publish(ctx, event.ETDatasetCommitChange, event.DsChange{InitID: movies.InitID}); err != nil {
+		t.Fatal(err)
+	}
+	if res := n.serveResolveRef(ctx, &resolveRefMessage{Ref: req}); res.Status != resolveRefStatusFound {
+		t.Fatalf("expected found after head change, got status: %q", res.Status)
+	}
+	if calls := local.Calls(); calls != 2 {
+		t.Errorf("expected a head change to resolve locally again, resolved %d times", calls)
+	}
+}
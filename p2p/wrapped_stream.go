@@ -22,15 +22,37 @@ type WrappedStream struct {
 	r      *bufio.Reader
 }
 
+// DefaultStreamBufferSize is the default size in bytes of the read & write
+// buffers a WrappedStream allocates
+const DefaultStreamBufferSize = 4096
+
+// WrapStreamOptions configures a WrappedStream
+type WrapStreamOptions struct {
+	// ReadBufferSize is the size of the buffered reader in bytes
+	ReadBufferSize int
+	// WriteBufferSize is the size of the buffered writer in bytes
+	WriteBufferSize int
+}
+
 // WrapStream takes a stream and complements it with r/w bufios and
 // decoder/encoder. In order to write raw data to the stream we can use
 // wrap.w.Write(). To encode something into it we can wrap.enc.Encode().
 // Finally, we should wrap.w.Flush() to actually send the data. Handling
 // incoming data works similarly with wrap.r.Read() for raw-reading and
 // wrap.dec.Decode() to decode.
-func WrapStream(s net.Stream) *WrappedStream {
-	reader := bufio.NewReader(s)
-	writer := bufio.NewWriter(s)
+// Buffer sizes default to DefaultStreamBufferSize, high-throughput callers
+// can provide larger sizes with option functions
+func WrapStream(s net.Stream, opts ...func(o *WrapStreamOptions)) *WrappedStream {
+	o := &WrapStreamOptions{
+		ReadBufferSize:  DefaultStreamBufferSize,
+		WriteBufferSize: DefaultStreamBufferSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	reader := bufio.NewReaderSize(s, o.ReadBufferSize)
+	writer := bufio.NewWriterSize(s, o.WriteBufferSize)
 	// This is where we pick our specific multicodec. In order to change the
 	// codec, we only need to change this place.
 	// See https://godoc.org/github.com/multiformats/go-multicodec/json
@@ -45,6 +67,14 @@ func WrapStream(s net.Stream) *WrappedStream {
 	}
 }
 
+// BufferSizes sets both the read & write buffer sizes of a WrappedStream
+func BufferSizes(read, write int) func(o *WrapStreamOptions) {
+	return func(o *WrapStreamOptions) {
+		o.ReadBufferSize = read
+		o.WriteBufferSize = write
+	}
+}
+
 // TODO (ramfox): currently, each protocol (except for the one marked for deprecation)
 // has its own `receiveMessage` and `sendMessage` functions, that ensure the
 // messages get encoded and decoded to the structures they need. We should figure out
@@ -0,0 +1,89 @@
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	net "github.com/libp2p/go-libp2p-core/network"
+	"github.com/qri-io/qri/dsref"
+)
+
+// bufferStream is a net.Stream that reads & writes from an in-memory buffer,
+// counting calls to Read & Write. Only Read & Write are implemented
+type bufferStream struct {
+	net.Stream
+	buf           *bytes.Buffer
+	reads, writes int
+}
+
+func (s *bufferStream) Read(p []byte) (int, error) {
+	s.reads++
+	return s.buf.Read(p)
+}
+
+func (s *bufferStream) Write(p []byte) (int, error) {
+	s.writes++
+	return s.buf.Write(p)
+}
+
+func TestWrapStreamBufferSizes(t *testing.T) {
+	s := &bufferStream{buf: &bytes.Buffer{}}
+	ws := WrapStream(s)
+	if ws.r.Size() != DefaultStreamBufferSize {
+		t.Errorf("default read buffer size mismatch. expected: %d, got: %d", DefaultStreamBufferSize, ws.r.Size())
+	}
+	if ws.w.Size() != DefaultStreamBufferSize {
+		t.Errorf("default write buffer size mismatch. expected: %d, got: %d", DefaultStreamBufferSize, ws.w.Size())
+	}
+
+	ws = WrapStream(s, BufferSizes(8192, 16384))
+	if ws.r.Size() != 8192 {
+		t.Errorf("read buffer size mismatch. expected: %d, got: %d", 8192, ws.r.Size())
+	}
+	if ws.w.Size() != 16384 {
+		t.Errorf("write buffer size mismatch. expected: %d, got: %d", 16384, ws.w.Size())
+	}
+}
+
+func BenchmarkWrapStreamBatchExchange(b *testing.B) {
+	refs := make([]dsref.Ref, 1000)
+	for i := range refs {
+		refs[i] = dsref.Ref{
+			InitID:    fmt.Sprintf("init_id_%d", i),
+			Username:  "peer",
+			ProfileID: "QmYCvbfNbCwFR45HiNP45rwJgvatpiW38D961L5qAhUM5Y",
+			Name:      fmt.Sprintf("dataset_%d", i),
+			Path:      "/ipfs/QmeXaMpLe",
+		}
+	}
+
+	cases := []struct {
+		name string
+		opts []func(*WrapStreamOptions)
+	}{
+		{"default", nil},
+		{"64KiB", []func(*WrapStreamOptions){BufferSizes(64*1024, 64*1024)}},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			s := &bufferStream{buf: &bytes.Buffer{}}
+			for i := 0; i < b.N; i++ {
+				ws := WrapStream(s, c.opts...)
+				if err := ws.enc.Encode(refs); err != nil {
+					b.Fatal(err)
+				}
+				if err := ws.w.Flush(); err != nil {
+					b.Fatal(err)
+				}
+
+				got := []dsref.Ref{}
+				if err := ws.dec.Decode(&got); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(s.reads+s.writes)/float64(b.N), "syscalls/op")
+		})
+	}
+}
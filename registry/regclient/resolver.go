@@ -0,0 +1,188 @@
+package regclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qri-io/qri/dsref"
+)
+
+const (
+	// DefaultResolverCacheTTL is the default length of time a RegistryResolver
+	// caches resolutions
+	DefaultResolverCacheTTL = time.Second * 30
+	// DefaultRateLimitBackoff is the length of time a RegistryResolver stops
+	// making requests after the registry rate limits it, if the registry
+	// doesn't say when to retry
+	DefaultRateLimitBackoff = time.Second * 30
+)
+
+var (
+	// ErrRateLimited indicates the registry is rejecting requests for
+	// exceeding its rate limit
+	ErrRateLimited = errors.New("registry: rate limited")
+	// ErrUnauthorized indicates the registry rejected a request's credentials
+	ErrUnauthorized = errors.New("registry: unauthorized")
+)
+
+// RegistryResolverOptions configures a RegistryResolver
+type RegistryResolverOptions struct {
+	// Token is sent as a bearer token with each request, for registries that
+	// require authorization
+	Token string
+	// CacheTTL is the length of time to cache successful resolutions. Zero
+	// disables caching
+	CacheTTL time.Duration
+}
+
+// RegistryResolver resolves human-friendly dataset names through a
+// registry's HTTP API. It's intended as a fallback at the end of a
+// dsref.SequentialResolver chain. Results are cached briefly, and the resolver
+// stops making requests for as long as the registry asks when rate limited
+type RegistryResolver struct {
+	location   string
+	token      string
+	httpClient *http.Client
+	cache      *dsref.CacheResolver
+	// now returns the current time, overridden in tests
+	now func() time.Time
+
+	lk           sync.Mutex
+	limitedUntil time.Time
+}
+
+// assert at compile time that RegistryResolver is a dsref.Resolver
+var _ dsref.Resolver = (*RegistryResolver)(nil)
+
+// NewRegistryResolver creates a resolver backed by the client's registry
+func (c *Client) NewRegistryResolver(opts ...func(o *RegistryResolverOptions)) *RegistryResolver {
+	o := &RegistryResolverOptions{
+		CacheTTL: DefaultResolverCacheTTL,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	rr := &RegistryResolver{
+		location:   c.cfg.Location,
+		token:      o.Token,
+		httpClient: c.httpClient,
+		now:        time.Now,
+	}
+	if o.CacheTTL > 0 {
+		rr.cache = dsref.NewCacheResolver(registryLookup{rr}, func(co *dsref.CacheResolverOptions) {
+			co.TTL = o.CacheTTL
+			if co.NegativeTTL > o.CacheTTL {
+				co.NegativeTTL = o.CacheTTL
+			}
+		})
+	}
+	return rr
+}
+
+// ResolveRef asks the registry to resolve a reference, returning the
+// registry location as the source
+func (rr *RegistryResolver) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
+	if rr == nil || ref == nil || rr.location == "" {
+		return "", dsref.ErrRefNotFound
+	}
+	if rr.cache != nil {
+		return rr.cache.ResolveRef(ctx, ref)
+	}
+	return rr.resolve(ctx, ref)
+}
+
+// registryLookup adapts a RegistryResolver's uncached lookups for caching
+type registryLookup struct {
+	rr *RegistryResolver
+}
+
+func (l registryLookup) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
+	return l.rr.resolve(ctx, ref)
+}
+
+func (rr *RegistryResolver) resolve(ctx context.Context, ref *dsref.Ref) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	rr.lk.Lock()
+	limitedUntil := rr.limitedUntil
+	rr.lk.Unlock()
+	if rr.now().Before(limitedUntil) {
+		return "", fmt.Errorf("%w: retry after %s", ErrRateLimited, limitedUntil.Format(time.RFC3339))
+	}
+
+	u, err := url.Parse(rr.location)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/remote/refs"
+	q := u.Query()
+	q.Set("username", ref.Username)
+	q.Set("name", ref.Name)
+	q.Set("path", ref.Path)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	if rr.token != "" {
+		req.Header.Set("Authorization", "Bearer "+rr.token)
+	}
+
+	res, err := rr.httpClient.Do(req)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such host") {
+			return "", ErrNoRegistry
+		}
+		return "", err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		resolved := dsref.Ref{}
+		if err := json.NewDecoder(res.Body).Decode(&resolved); err != nil {
+			return "", fmt.Errorf("decoding registry response: %w", err)
+		}
+		*ref = resolved
+		return rr.location, nil
+	case http.StatusNotFound:
+		return "", dsref.ErrRefNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", fmt.Errorf("%w: %s", ErrUnauthorized, responseMessage(res))
+	case http.StatusTooManyRequests:
+		until := rr.now().Add(retryAfter(res.Header.Get("Retry-After")))
+		rr.lk.Lock()
+		rr.limitedUntil = until
+		rr.lk.Unlock()
+		return "", fmt.Errorf("%w: retry after %s", ErrRateLimited, until.Format(time.RFC3339))
+	default:
+		return "", fmt.Errorf("error %d: %s", res.StatusCode, responseMessage(res))
+	}
+}
+
+// retryAfter interprets a Retry-After header given in seconds
+func retryAfter(header string) time.Duration {
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return DefaultRateLimitBackoff
+}
+
+func responseMessage(res *http.Response) string {
+	msg, _ := ioutil.ReadAll(res.Body)
+	return strings.TrimSpace(string(msg))
+}
@@ -0,0 +1,121 @@
+package regclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/dsref"
+)
+
+func TestRegistryResolver(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := (*RegistryResolver)(nil).ResolveRef(ctx, nil); err != dsref.ErrRefNotFound {
+		t.Errorf("ResolveRef must be nil-callable. expected: %q, got %v", dsref.ErrRefNotFound, err)
+	}
+
+	expect := dsref.Ref{InitID: "init_id", Username: "peer", ProfileID: "QmProfileID", Name: "dataset", Path: "/ipfs/QmeXaMpLe"}
+	refs := dsref.StaticResolver(map[string]dsref.Ref{"peer/dataset": expect})
+
+	var (
+		requests    int
+		rateLimited bool
+	)
+	// stub registry serving the /remote/refs endpoint, requiring a token
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.URL.Path != "/remote/refs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		if rateLimited {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		ref := dsref.Ref{Username: req.FormValue("username"), Name: req.FormValue("name"), Path: req.FormValue("path")}
+		if _, err := refs.ResolveRef(req.Context(), &ref); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(ref)
+	}))
+	defer srv.Close()
+
+	c := NewClient(&Config{Location: srv.URL})
+	rr := c.NewRegistryResolver(func(o *RegistryResolverOptions) {
+		o.Token = "secret"
+	})
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	rr.now = func() time.Time { return now }
+
+	got := dsref.Ref{Username: "peer", Name: "dataset"}
+	source, err := rr.ResolveRef(ctx, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != srv.URL {
+		t.Errorf("expected source to be the registry %q, got: %q", srv.URL, source)
+	}
+	if !expect.Equals(got) {
+		t.Errorf("result mismatch. expected: %s, got: %s", expect, got)
+	}
+
+	// results are cached
+	got = dsref.Ref{Username: "peer", Name: "dataset"}
+	if _, err := rr.ResolveRef(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected cached resolution to skip the registry, got %d requests", requests)
+	}
+
+	if _, err := rr.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "missing"}); !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Errorf("expected ErrRefNotFound, got: %v", err)
+	}
+
+	// a rate limited resolver stops making requests until the registry's
+	// retry time
+	rateLimited = true
+	if _, err := rr.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "other"}); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got: %v", err)
+	}
+	requests = 0
+	now = now.Add(time.Second * 30)
+	if _, err := rr.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "other"}); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got: %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected rate limited resolver not to make requests, got: %d", requests)
+	}
+	rateLimited = false
+	now = now.Add(time.Minute)
+	got = dsref.Ref{Username: "peer", Name: "dataset", Path: "/ipfs/QmeXaMpLe"}
+	if _, err := rr.ResolveRef(ctx, &got); err != nil {
+		t.Errorf("expected resolution to succeed after the retry time, got: %s", err)
+	}
+
+	// registries reject bad credentials
+	unauthorized := c.NewRegistryResolver(func(o *RegistryResolverOptions) {
+		o.Token = "wrong"
+	})
+	if _, err := unauthorized.ResolveRef(ctx, &dsref.Ref{Username: "peer", Name: "dataset"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got: %v", err)
+	}
+
+	// as a fallback in a resolver chain
+	chain := dsref.SequentialResolver(dsref.NoopResolver(), rr)
+	got = dsref.Ref{Username: "peer", Name: "dataset"}
+	if source, err := chain.ResolveRef(ctx, &got); err != nil || source != srv.URL {
+		t.Errorf("expected chain to resolve from the registry. source: %q, err: %v", source, err)
+	}
+}
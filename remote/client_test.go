@@ -86,8 +86,8 @@ func TestNewRemoteRefResolver(t *testing.T) {
 	cli := tr.NodeBClient(t)
 	resolver := cli.NewRemoteRefResolver(s.URL)
 
-	dsrefspec.AssertResolverSpec(t, resolver, func(r dsref.Ref, author identity.Author, log *oplog.Log) error {
-		return remA.Node().Repo.Logbook().MergeLog(context.Background(), author, log)
+	dsrefspec.AssertResolverSpec(t, resolver, func(ctx context.Context, r dsref.Ref, author identity.Author, log *oplog.Log) error {
+		return remA.Node().Repo.Logbook().MergeLog(ctx, author, log)
 	})
 }
 
@@ -111,5 +111,7 @@ func newDscache(ctx context.Context, fs qfs.Filesystem, bus event.Bus, book *log
 		return nil, fmt.Errorf("invalid repo path: %q", repoPath)
 	}
 	dscachePath := filepath.Join(repoPath, "dscache.qfb")
-	return dscache.NewDscache(ctx, fs, bus, username, dscachePath), nil
+	return dscache.NewDscache(ctx, fs, bus, username, dscachePath, func(o *dscache.Options) {
+		o.Logbook = book
+	}), nil
 }
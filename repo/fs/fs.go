@@ -3,6 +3,7 @@ package fsrepo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -40,6 +41,17 @@ type Repo struct {
 	dscache  *dscache.Dscache
 	profiles *ProfileStore
 
+	putRefHooksLk sync.Mutex
+	putRefHooks   []PutRefHook
+	// putRefLk serializes hooked PutRef calls so rollbacks can't interleave
+	putRefLk sync.Mutex
+
+	// pathIndex maps dataset version paths to the name of the dataset they
+	// belong to. It's built on the first lookup by path, and dropped whenever
+	// refs or logbook history change
+	pathIndexLk sync.Mutex
+	pathIndex   map[string]reporef.DatasetRef
+
 	doneWg  sync.WaitGroup
 	doneCh  chan struct{}
 	doneErr error
@@ -47,8 +59,17 @@ type Repo struct {
 
 var _ repo.Repo = (*Repo)(nil)
 
+// Options configures a Repo
+type Options struct {
+	// Refstore maps dataset names to references. Defaults to a Refstore
+	// backed by a file in the repo directory. Alternative implementations
+	// (like an embedded key-value store) must pass the refstore tests in
+	// repo/test/spec
+	Refstore repo.Refstore
+}
+
 // NewRepo creates a new file-based repository
-func NewRepo(path string, fsys *muxfs.Mux, book *logbook.Book, cache *dscache.Dscache, pro *profile.Profile, bus event.Bus) (repo.Repo, error) {
+func NewRepo(path string, fsys *muxfs.Mux, book *logbook.Book, cache *dscache.Dscache, pro *profile.Profile, bus event.Bus, opts ...func(o *Options)) (repo.Repo, error) {
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
 		log.Error(err)
 		return nil, err
@@ -59,6 +80,17 @@ func NewRepo(path string, fsys *muxfs.Mux, book *logbook.Book, cache *dscache.Ds
 		return nil, fmt.Errorf("Expected: PrivateKey")
 	}
 
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Refstore == nil {
+		if _, err := maybeCreateFlatbufferRefsFile(path); err != nil {
+			return nil, err
+		}
+		o.Refstore = Refstore{basepath: bp, file: FileRefs, aliasFile: FileAliases}
+	}
+
 	r := &Repo{
 		profile: pro,
 
@@ -68,12 +100,21 @@ func NewRepo(path string, fsys *muxfs.Mux, book *logbook.Book, cache *dscache.Ds
 		logbook:  book,
 		dscache:  cache,
 
-		Refstore: Refstore{basepath: bp, file: FileRefs},
+		Refstore: o.Refstore,
 		profiles: NewProfileStore(bp),
 
 		doneCh: make(chan struct{}),
 	}
 
+	bus.Subscribe(func(_ context.Context, _ event.Type, _ interface{}) error {
+		r.dropPathIndex()
+		return nil
+	},
+		event.ETDatasetNameInit,
+		event.ETDatasetCommitChange,
+		event.ETDatasetDeleteAll,
+		event.ETDatasetRename)
+
 	r.doneWg.Add(1)
 	go func() {
 		<-r.fsys.Done()
@@ -86,10 +127,6 @@ func NewRepo(path string, fsys *muxfs.Mux, book *logbook.Book, cache *dscache.Ds
 		close(r.doneCh)
 	}()
 
-	if _, err := maybeCreateFlatbufferRefsFile(path); err != nil {
-		return nil, err
-	}
-
 	// add our own profile to the store if it doesn't already exist.
 	if _, e := r.Profiles().GetProfile(pro.ID); e != nil {
 		if err := r.Profiles().PutProfile(pro); err != nil {
@@ -100,12 +137,82 @@ func NewRepo(path string, fsys *muxfs.Mux, book *logbook.Book, cache *dscache.Ds
 	return r, nil
 }
 
+// PutRefHook is a function called after a reference is successfully written
+// to the repo's refstore. name is the human-friendly "peername/name" alias of
+// ref. Returning an error rolls back the write and aborts the PutRef call
+type PutRefHook func(name string, ref reporef.DatasetRef) error
+
+// RegisterPutRefHook adds a hook that fires after each successful PutRef.
+// hooks are called synchronously in the order they were registered
+func (r *Repo) RegisterPutRefHook(hook PutRefHook) {
+	r.putRefHooksLk.Lock()
+	defer r.putRefHooksLk.Unlock()
+	r.putRefHooks = append(r.putRefHooks, hook)
+}
+
+// PutRef adds a reference to the repo's refstore, calling any registered
+// PutRefHooks on success
+func (r *Repo) PutRef(ref reporef.DatasetRef) error {
+	r.dropPathIndex()
+
+	r.putRefHooksLk.Lock()
+	hooks := r.putRefHooks
+	r.putRefHooksLk.Unlock()
+
+	if len(hooks) == 0 {
+		return r.Refstore.PutRef(ref)
+	}
+
+	// hold the lock across the write, hooks & any rollback so concurrent puts
+	// can't clobber each other's snapshots
+	r.putRefLk.Lock()
+	defer r.putRefLk.Unlock()
+
+	// snapshot & roll back by name. GetRef also matches by path, which would
+	// snapshot an unrelated dataset that shares this ref's path. The write still
+	// replaces any dataset at the same path, so snapshot that separately
+	named := reporef.DatasetRef{Peername: ref.Peername, Name: ref.Name}
+	prev, getErr := r.Refstore.GetRef(named)
+	displaced, dispErr := r.Refstore.GetRef(reporef.DatasetRef{Path: ref.Path})
+	if dispErr == nil && displaced.Peername == ref.Peername && displaced.Name == ref.Name {
+		dispErr = repo.ErrNotFound
+	}
+	if err := r.Refstore.PutRef(ref); err != nil {
+		return err
+	}
+
+	// refstores drop dataset pointers on write, hooks should see what was stored
+	ref.Dataset = nil
+	for _, hook := range hooks {
+		if err := hook(ref.AliasString(), ref); err != nil {
+			if getErr == nil {
+				if rbErr := r.Refstore.PutRef(prev); rbErr != nil {
+					log.Errorf("rolling back PutRef: %s", rbErr)
+				}
+			} else if rbErr := r.Refstore.DeleteRef(named); rbErr != nil {
+				log.Errorf("rolling back PutRef: %s", rbErr)
+			}
+			if dispErr == nil {
+				if rbErr := r.Refstore.PutRef(displaced); rbErr != nil {
+					log.Errorf("rolling back PutRef: %s", rbErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 // ResolveRef implements the dsref.RefResolver interface
 func (r *Repo) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
 	if r == nil {
 		return "", dsref.ErrRefNotFound
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	// TODO (b5) - not totally sure why, but memRepo doesn't seem to be wiring up
 	// dscache correctly in in tests
 	// if r.dscache != nil {
@@ -123,9 +230,21 @@ func (r *Repo) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
 		Name:     ref.Name,
 	}
 
-	// Get the reference from the refstore. This has everything but initID
-	match, err := r.GetRef(datasetRef)
+	var (
+		match reporef.DatasetRef
+		err   error
+	)
+	if ref.Username == "" && ref.Name == "" && ref.Path != "" {
+		// back-fill human-friendly fields for path-only references
+		match, err = r.refForPath(ctx, ref.Path)
+	} else {
+		// Get the reference from the refstore. This has everything but initID
+		match, err = r.GetRef(datasetRef)
+	}
 	if err != nil {
+		if errors.Is(err, repo.ErrDanglingAlias) {
+			return "", fmt.Errorf("%w: %s", dsref.ErrRefNotFound, err)
+		}
 		return "", dsref.ErrRefNotFound
 	}
 	// Create our resolved reference. If the input ref had a path, reassign that
@@ -139,6 +258,88 @@ func (r *Repo) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error) {
 	return "", err
 }
 
+// PutAlias makes alias resolve to target, see repo.AliasStore
+func (r *Repo) PutAlias(ctx context.Context, alias, target string) error {
+	as, ok := r.Refstore.(repo.AliasStore)
+	if !ok {
+		return fmt.Errorf("refstore doesn't support aliases")
+	}
+	return as.PutAlias(ctx, alias, target)
+}
+
+// DeleteAlias removes an alias, see repo.AliasStore
+func (r *Repo) DeleteAlias(ctx context.Context, alias string) error {
+	as, ok := r.Refstore.(repo.AliasStore)
+	if !ok {
+		return fmt.Errorf("refstore doesn't support aliases")
+	}
+	return as.DeleteAlias(ctx, alias)
+}
+
+// refForPath finds the refstore entry for a dataset with a version at path,
+// checking head paths in the refstore before searching logbook history
+func (r *Repo) refForPath(ctx context.Context, path string) (reporef.DatasetRef, error) {
+	if match, err := r.GetRef(reporef.DatasetRef{Path: path}); err == nil {
+		return match, nil
+	}
+
+	index, err := r.versionPathIndex(ctx)
+	if err != nil {
+		return reporef.DatasetRef{}, err
+	}
+	named, ok := index[path]
+	if !ok {
+		return reporef.DatasetRef{}, repo.ErrNotFound
+	}
+	return r.GetRef(named)
+}
+
+// versionPathIndex returns the path index, building it from the logbook
+// history of every dataset in the refstore if needed
+func (r *Repo) versionPathIndex(ctx context.Context) (map[string]reporef.DatasetRef, error) {
+	r.pathIndexLk.Lock()
+	defer r.pathIndexLk.Unlock()
+	if r.pathIndex != nil {
+		return r.pathIndex, nil
+	}
+
+	num, err := r.RefCount()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := r.References(0, num)
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]reporef.DatasetRef{}
+	for _, ref := range refs {
+		items, err := r.logbook.Items(ctx, dsref.Ref{Username: ref.Peername, Name: ref.Name}, 0, -1)
+		if err != nil {
+			continue
+		}
+		for _, item := range items {
+			if _, ok := index[item.Path]; !ok && item.Path != "" {
+				index[item.Path] = reporef.DatasetRef{Peername: ref.Peername, Name: ref.Name}
+			}
+		}
+	}
+	r.pathIndex = index
+	return index, nil
+}
+
+// dropPathIndex discards the path index, to be rebuilt on the next lookup
+func (r *Repo) dropPathIndex() {
+	r.pathIndexLk.Lock()
+	defer r.pathIndexLk.Unlock()
+	r.pathIndex = nil
+}
+
+// GC removes unreferenced dataset versions from the store, see
+// repo.CollectGarbage
+func (r *Repo) GC(ctx context.Context) (*repo.GCResult, error) {
+	return repo.CollectGarbage(ctx, r)
+}
+
 // Path returns the path to the root of the repo directory
 func (r *Repo) Path() string {
 	return string(r.basepath)
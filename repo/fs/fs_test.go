@@ -2,10 +2,14 @@ package fsrepo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/qri-io/dataset"
 	"github.com/qri-io/qfs"
 	"github.com/qri-io/qfs/muxfs"
 	"github.com/qri-io/qri/config"
@@ -23,6 +27,18 @@ import (
 )
 
 func TestRepo(t *testing.T) {
+	testRepoSpec(t, nil)
+}
+
+// TestRepoAlternateRefstore runs the repo spec against a repo with an
+// in-memory refstore, confirming refstores are interchangeable
+func TestRepoAlternateRefstore(t *testing.T) {
+	testRepoSpec(t, func() repo.Refstore { return &repo.MemRefstore{} })
+}
+
+// testRepoSpec runs the repo spec against a file-based repo. If newRefstore
+// is non-nil, each repo is created with the refstore it returns
+func testRepoSpec(t *testing.T, newRefstore func() repo.Refstore) {
 	path, err := ioutil.TempDir("", "qri_repo_test")
 	if err != nil {
 		t.Fatal(err)
@@ -56,12 +72,21 @@ func TestRepo(t *testing.T) {
 
 		cache := dscache.NewDscache(ctx, fs, bus, pro.Peername, "")
 
-		r, err := NewRepo(path, fs, book, cache, pro, bus)
+		var opts []func(o *Options)
+		if newRefstore != nil {
+			opts = append(opts, func(o *Options) { o.Refstore = newRefstore() })
+		}
+		r, err := NewRepo(path, fs, book, cache, pro, bus, opts...)
 		if err != nil {
 			t.Fatalf("error creating repo: %s", err.Error())
 		}
 
 		cleanup := func() {
+			if newRefstore != nil {
+				if _, err := os.Stat(basepath(path).filepath(FileRefs)); !os.IsNotExist(err) {
+					t.Errorf("expected alternate refstore to leave refs file unwritten")
+				}
+			}
 			if err := os.RemoveAll(path); err != nil {
 				t.Errorf("error cleaning up after test: %s", err)
 			}
@@ -112,7 +137,7 @@ func TestResolveRef(t *testing.T) {
 		t.Fatalf("error creating repo: %s", err.Error())
 	}
 
-	dsrefspec.AssertResolverSpec(t, r, func(ref dsref.Ref, author identity.Author, log *oplog.Log) error {
+	dsrefspec.AssertResolverSpec(t, r, func(ctx context.Context, ref dsref.Ref, author identity.Author, log *oplog.Log) error {
 		datasetRef := reporef.RefFromDsref(ref)
 		err := r.PutRef(datasetRef)
 		if err != nil {
@@ -121,3 +146,303 @@ func TestResolveRef(t *testing.T) {
 		return r.Logbook().MergeLog(ctx, author, log)
 	})
 }
+
+func TestPutRefHook(t *testing.T) {
+	path, err := ioutil.TempDir("", "qri_repo_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	r := newTestRepo(t, path)
+
+	var (
+		gotName string
+		gotRef  reporef.DatasetRef
+		calls   int
+	)
+	r.RegisterPutRefHook(func(name string, ref reporef.DatasetRef) error {
+		calls++
+		gotName = name
+		gotRef = ref
+		return nil
+	})
+
+	ref := reporef.DatasetRef{
+		Peername:  "peer",
+		ProfileID: profile.IDB58MustDecode("QmYCvbfNbCwFR45HiNP45rwJgvatpiW38D961L5qAhUM5Y"),
+		Name:      "hooked",
+		Path:      "/ipfs/QmHookedPath",
+	}
+	if err := r.PutRef(ref); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected hook to be called once, got: %d", calls)
+	}
+	if gotName != "peer/hooked" {
+		t.Errorf("hook name mismatch. expected: %q, got: %q", "peer/hooked", gotName)
+	}
+	if !gotRef.Equal(ref) {
+		t.Errorf("hook ref mismatch. expected: %s, got: %s", ref, gotRef)
+	}
+
+	// hook errors abort the write
+	errAbort := fmt.Errorf("abort")
+	r.RegisterPutRefHook(func(name string, ref reporef.DatasetRef) error {
+		return errAbort
+	})
+
+	update := ref
+	update.Path = "/ipfs/QmUpdatedPath"
+	if err := r.PutRef(update); !errors.Is(err, errAbort) {
+		t.Errorf("expected hook error to be returned, got: %v", err)
+	}
+	got, err := r.GetRef(reporef.DatasetRef{Peername: "peer", Name: "hooked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(ref) {
+		t.Errorf("expected aborted PutRef to leave existing ref in place. expected: %s, got: %s", ref, got)
+	}
+
+	added := reporef.DatasetRef{
+		Peername:  "peer",
+		ProfileID: profile.IDB58MustDecode("QmYCvbfNbCwFR45HiNP45rwJgvatpiW38D961L5qAhUM5Y"),
+		Name:      "never_added",
+		Path:      "/ipfs/QmNeverAdded",
+	}
+	if err := r.PutRef(added); !errors.Is(err, errAbort) {
+		t.Errorf("expected hook error to be returned, got: %v", err)
+	}
+	if _, err := r.GetRef(reporef.DatasetRef{Peername: "peer", Name: "never_added"}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected aborted PutRef to not store a new ref, got: %v", err)
+	}
+
+	// a new ref sharing an existing ref's path must roll back to nothing, not
+	// to the dataset that holds the path
+	shared := added
+	shared.Name = "shared_path"
+	shared.Path = ref.Path
+	if err := r.PutRef(shared); !errors.Is(err, errAbort) {
+		t.Errorf("expected hook error to be returned, got: %v", err)
+	}
+	if _, err := r.GetRef(reporef.DatasetRef{Peername: "peer", Name: "shared_path"}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected aborted PutRef to not store a ref sharing a path, got: %v", err)
+	}
+	if got, err := r.GetRef(reporef.DatasetRef{Peername: "peer", Name: "hooked"}); err != nil || !got.Equal(ref) {
+		t.Errorf("expected aborted PutRef to leave the path's dataset in place. expected: %s, got: %s (%v)", ref, got, err)
+	}
+}
+
+func newTestRepo(t *testing.T, path string) *Repo {
+	pro, err := profile.NewProfile(config.DefaultProfileForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	bus := event.NewBus(ctx)
+	fs, err := muxfs.New(ctx, []qfs.Config{
+		{Type: "map"},
+		{Type: "mem"},
+		{Type: "local"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := logbook.NewJournal(pro.PrivKey, pro.Peername, bus, fs, "/mem/logbook.qfb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := dscache.NewDscache(ctx, fs, bus, pro.Peername, "")
+
+	r, err := NewRepo(path, fs, book, cache, pro, bus)
+	if err != nil {
+		t.Fatalf("error creating repo: %s", err.Error())
+	}
+	return r.(*Repo)
+}
+
+func TestResolveRefByPath(t *testing.T) {
+	path, err := ioutil.TempDir("", "qri_repo_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	ctx := context.Background()
+	r := newTestRepo(t, path)
+
+	journal := dsrefspec.ForeignLogbook(t, "path_peer")
+	initID, err := journal.WriteDatasetInit(ctx, "by_path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range []string{"/ipfs/QmVersionOne", "/ipfs/QmVersionTwo"} {
+		err = journal.WriteVersionSave(ctx, initID, &dataset.Dataset{
+			Peername: "path_peer",
+			Name:     "by_path",
+			Commit:   &dataset.Commit{Title: fmt.Sprintf("commit %d", i)},
+			Path:     p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	lg, err := journal.UserDatasetBranchesLog(ctx, initID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.SignLog(lg); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Logbook().MergeLog(ctx, journal.Author(), lg); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PutRef(reporef.DatasetRef{
+		Peername:  "path_peer",
+		ProfileID: profile.IDB58MustDecode("QmYCvbfNbCwFR45HiNP45rwJgvatpiW38D961L5qAhUM5Y"),
+		Name:      "by_path",
+		Path:      "/ipfs/QmVersionTwo",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// both the head version and a historical version back-fill human fields
+	for _, p := range []string{"/ipfs/QmVersionTwo", "/ipfs/QmVersionOne"} {
+		ref := dsref.Ref{Path: p}
+		if _, err := r.ResolveRef(ctx, &ref); err != nil {
+			t.Fatalf("resolving %q: %s", p, err)
+		}
+		if ref.Username != "path_peer" || ref.Name != "by_path" {
+			t.Errorf("expected human-friendly fields to be populated resolving %q, got: %s", p, ref)
+		}
+		if ref.Path != p {
+			t.Errorf("expected path to be preserved. want: %q, got: %q", p, ref.Path)
+		}
+		if ref.InitID != initID {
+			t.Errorf("init ID mismatch. want: %q, got: %q", initID, ref.InitID)
+		}
+	}
+
+	if _, err := r.ResolveRef(ctx, &dsref.Ref{Path: "/ipfs/QmUnknown"}); err != dsref.ErrRefNotFound {
+		t.Errorf("expected ErrRefNotFound for unknown path, got: %v", err)
+	}
+
+	// versions saved after the first lookup by path are found
+	for i, p := range []string{"/ipfs/QmVersionThree", "/ipfs/QmVersionFour"} {
+		err = journal.WriteVersionSave(ctx, initID, &dataset.Dataset{
+			Peername: "path_peer",
+			Name:     "by_path",
+			Commit:   &dataset.Commit{Title: fmt.Sprintf("commit %d", i+2)},
+			Path:     p,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lg, err = journal.UserDatasetBranchesLog(ctx, initID); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.SignLog(lg); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Logbook().MergeLog(ctx, journal.Author(), lg); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PutRef(reporef.DatasetRef{
+		Peername:  "path_peer",
+		ProfileID: profile.IDB58MustDecode("QmYCvbfNbCwFR45HiNP45rwJgvatpiW38D961L5qAhUM5Y"),
+		Name:      "by_path",
+		Path:      "/ipfs/QmVersionFour",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ref := dsref.Ref{Path: "/ipfs/QmVersionThree"}
+	if _, err := r.ResolveRef(ctx, &ref); err != nil {
+		t.Fatalf("resolving version saved after indexing: %s", err)
+	}
+	if ref.Username != "path_peer" || ref.Name != "by_path" {
+		t.Errorf("expected human-friendly fields to be populated, got: %s", ref)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	path, err := ioutil.TempDir("", "qri_repo_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	ctx := context.Background()
+	r := newTestRepo(t, path)
+
+	target := reporef.DatasetRef{
+		Peername:  "alias_peer",
+		ProfileID: profile.IDB58MustDecode("QmYCvbfNbCwFR45HiNP45rwJgvatpiW38D961L5qAhUM5Y"),
+		Name:      "target",
+		Path:      "/ipfs/QmVersionOne",
+	}
+	if err := r.PutRef(target); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.PutAlias(ctx, "alias_peer/old_name", "alias_peer/missing"); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected aliasing a missing dataset to return ErrNotFound, got: %v", err)
+	}
+	if err := r.PutAlias(ctx, "alias_peer/target", "alias_peer/target"); !errors.Is(err, repo.ErrNameTaken) {
+		t.Errorf("expected aliasing an existing dataset name to return ErrNameTaken, got: %v", err)
+	}
+	if err := r.PutAlias(ctx, "alias_peer/old_name", "alias_peer/target"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PutAlias(ctx, "alias_peer/older_name", "alias_peer/old_name"); err == nil {
+		t.Errorf("expected aliasing an alias to fail")
+	}
+
+	alias := reporef.DatasetRef{Peername: "alias_peer", Name: "old_name"}
+	got, err := r.GetRef(alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "/ipfs/QmVersionOne" {
+		t.Errorf("expected alias to resolve to target path %q, got: %q", "/ipfs/QmVersionOne", got.Path)
+	}
+
+	// the alias follows the target as it updates
+	target.Path = "/ipfs/QmVersionTwo"
+	if err := r.PutRef(target); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = r.GetRef(alias); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "/ipfs/QmVersionTwo" {
+		t.Errorf("expected alias to follow target to path %q, got: %q", "/ipfs/QmVersionTwo", got.Path)
+	}
+
+	// deleting the target leaves a dangling alias
+	if err := r.DeleteRef(target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetRef(alias); !errors.Is(err, repo.ErrDanglingAlias) {
+		t.Errorf("expected getting a dangling alias to return ErrDanglingAlias, got: %v", err)
+	}
+	_, err = r.ResolveRef(ctx, &dsref.Ref{Username: "alias_peer", Name: "old_name"})
+	if !errors.Is(err, dsref.ErrRefNotFound) {
+		t.Errorf("expected resolving a dangling alias to return ErrRefNotFound, got: %v", err)
+	} else if !strings.Contains(err.Error(), repo.ErrDanglingAlias.Error()) {
+		t.Errorf("expected resolution error to describe the dangling alias, got: %q", err)
+	}
+
+	if err := r.DeleteAlias(ctx, "alias_peer/old_name"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetRef(alias); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected deleted alias to return ErrNotFound, got: %v", err)
+	}
+}
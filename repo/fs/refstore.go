@@ -1,12 +1,15 @@
 package fsrepo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"sort"
 
+	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/repo"
 	reporef "github.com/qri-io/qri/repo/ref"
 )
@@ -16,8 +19,13 @@ import (
 type Refstore struct {
 	basepath
 	file File
+	// aliasFile stores aliases as a json object mapping alias to target
+	aliasFile File
 }
 
+// assert at compile time that Refstore supports aliases
+var _ repo.AliasStore = Refstore{}
+
 // PutRef adds a reference to the store
 func (rs Refstore) PutRef(r reporef.DatasetRef) (err error) {
 	var refs repo.RefList
@@ -54,7 +62,8 @@ func (rs Refstore) PutRef(r reporef.DatasetRef) (err error) {
 	return rs.save(refs)
 }
 
-// GetRef completes a partially-known reference
+// GetRef completes a partially-known reference. Getting an alias returns the
+// alias target's reference
 func (rs Refstore) GetRef(get reporef.DatasetRef) (reporef.DatasetRef, error) {
 	refs, err := rs.refs()
 	if err != nil {
@@ -65,9 +74,115 @@ func (rs Refstore) GetRef(get reporef.DatasetRef) (reporef.DatasetRef, error) {
 			return ref, nil
 		}
 	}
+
+	if get.Path == "" && get.Peername != "" && get.Name != "" {
+		aliases, err := rs.aliases()
+		if err != nil {
+			return reporef.DatasetRef{}, err
+		}
+		if target, ok := aliases[get.AliasString()]; ok {
+			return rs.getAliasTarget(refs, get.AliasString(), target)
+		}
+	}
 	return reporef.DatasetRef{}, repo.ErrNotFound
 }
 
+func (rs Refstore) getAliasTarget(refs repo.RefList, alias, target string) (reporef.DatasetRef, error) {
+	tref, err := dsref.ParseHumanFriendly(target)
+	if err != nil {
+		return reporef.DatasetRef{}, err
+	}
+	get := reporef.DatasetRef{Peername: tref.Username, Name: tref.Name}
+	for _, ref := range refs {
+		if ref.Match(get) {
+			return ref, nil
+		}
+	}
+	return reporef.DatasetRef{}, fmt.Errorf("%w: alias %q points to %q", repo.ErrDanglingAlias, alias, target)
+}
+
+// PutAlias makes alias resolve to target. Both alias & target are
+// "peername/name" strings
+func (rs Refstore) PutAlias(ctx context.Context, alias, target string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	aref, err := dsref.ParseHumanFriendly(alias)
+	if err != nil {
+		return fmt.Errorf("invalid alias: %w", err)
+	}
+	tref, err := dsref.ParseHumanFriendly(target)
+	if err != nil {
+		return fmt.Errorf("invalid alias target: %w", err)
+	}
+	alias, target = aref.Human(), tref.Human()
+
+	aliases, err := rs.aliases()
+	if err != nil {
+		return err
+	}
+	if _, ok := aliases[target]; ok {
+		return fmt.Errorf("alias target %q is itself an alias", target)
+	}
+	refs, err := rs.refs()
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if ref.Match(reporef.DatasetRef{Peername: aref.Username, Name: aref.Name}) {
+			return fmt.Errorf("%w: %q is a dataset", repo.ErrNameTaken, alias)
+		}
+	}
+	if _, err := rs.getAliasTarget(refs, alias, target); err != nil {
+		if errors.Is(err, repo.ErrDanglingAlias) {
+			return fmt.Errorf("%w: alias target %q", repo.ErrNotFound, target)
+		}
+		return err
+	}
+
+	aliases[alias] = target
+	return rs.saveFile(aliases, rs.aliasFile)
+}
+
+// DeleteAlias removes an alias from the store
+func (rs Refstore) DeleteAlias(ctx context.Context, alias string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	aref, err := dsref.ParseHumanFriendly(alias)
+	if err != nil {
+		return fmt.Errorf("invalid alias: %w", err)
+	}
+	alias = aref.Human()
+
+	aliases, err := rs.aliases()
+	if err != nil {
+		return err
+	}
+	if _, ok := aliases[alias]; !ok {
+		return repo.ErrNotFound
+	}
+	delete(aliases, alias)
+	return rs.saveFile(aliases, rs.aliasFile)
+}
+
+func (rs Refstore) aliases() (map[string]string, error) {
+	aliases := map[string]string{}
+	if rs.aliasFile == FileUnknown {
+		return aliases, nil
+	}
+	data, err := rs.readBytes(rs.aliasFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// empty is ok
+			return aliases, nil
+		}
+		return nil, fmt.Errorf("error loading aliases: %s", err.Error())
+	}
+	err = json.Unmarshal(data, &aliases)
+	return aliases, err
+}
+
 // DeleteRef removes a name from the store
 func (rs Refstore) DeleteRef(del reporef.DatasetRef) error {
 	refs, err := rs.refs()
@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/logbook"
+)
+
+// GCResult describes the outcome of garbage collection
+type GCResult struct {
+	// Removed lists dataset version paths removed from the store. Removing a
+	// version removes its root path, blocks the version shares with other
+	// data are left in place
+	Removed []string
+}
+
+// CollectGarbage removes dataset versions from the repo store that no name
+// points to and no logbook head references. Candidates for removal are drawn
+// from the logbook history of datasets in the refstore, and the set of
+// reachable paths is computed in full before anything is removed. Datasets
+// with a logbook but no refstore entry aren't walked: their versions are
+// neither collected nor counted as reachable. CollectGarbage is conservative:
+// any error reading the refstore or logbook aborts collection without
+// removing anything. Repo implementations use CollectGarbage to implement GC
+func CollectGarbage(ctx context.Context, r Repo) (*GCResult, error) {
+	num, err := r.RefCount()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := r.References(0, num)
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := map[string]struct{}{}
+	var candidates []logbook.DatasetLogItem
+	for _, ref := range refs {
+		if ref.Path != "" {
+			reachable[ref.Path] = struct{}{}
+		}
+
+		items, err := r.Logbook().Items(ctx, dsref.Ref{Username: ref.Peername, Name: ref.Name}, 0, -1)
+		if errors.Is(err, logbook.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			// items are ordered newest first
+			reachable[items[0].Path] = struct{}{}
+			candidates = append(candidates, items[1:]...)
+		}
+	}
+
+	res := &GCResult{}
+	removed := map[string]struct{}{}
+	for _, item := range candidates {
+		if item.Path == "" || item.Foreign {
+			continue
+		}
+		if _, ok := reachable[item.Path]; ok {
+			continue
+		}
+		if _, ok := removed[item.Path]; ok {
+			continue
+		}
+
+		if has, err := r.Store().Has(ctx, item.Path); err != nil {
+			return res, err
+		} else if !has {
+			continue
+		}
+		if err := r.Store().Delete(ctx, item.Path); err != nil {
+			return res, err
+		}
+		log.Debugf("GC removed unreferenced version %q", item.Path)
+		removed[item.Path] = struct{}{}
+		res.Removed = append(res.Removed, item.Path)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qfs"
+	"github.com/qri-io/qfs/muxfs"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/repo/profile"
+	reporef "github.com/qri-io/qri/repo/ref"
+)
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	fs, err := muxfs.New(ctx, []qfs.Config{
+		{Type: "map"},
+		{Type: "mem"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pro, err := profile.NewProfile(config.DefaultProfileForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewMemRepo(ctx, pro, fs, event.NilBus)
+	if err != nil {
+		t.Fatalf("error creating repo: %s", err.Error())
+	}
+
+	initID, err := r.Logbook().WriteDatasetInit(ctx, "gc_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// save writes a dataset version to the store & logbook, pointing the
+	// dataset name at it
+	save := func(title, prevPath string) string {
+		path, err := r.Store().Put(ctx, qfs.NewMemfileBytes("dataset.json", []byte(`{"commit":{"title":"`+title+`"}}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds := &dataset.Dataset{
+			Peername:     pro.Peername,
+			Name:         "gc_test",
+			Path:         path,
+			PreviousPath: prevPath,
+			Commit:       &dataset.Commit{Title: title},
+		}
+		if err := r.Logbook().WriteVersionSave(ctx, initID, ds); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.PutRef(reporef.DatasetRef{Peername: pro.Peername, ProfileID: pro.ID, Name: "gc_test", Path: path}); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	prev := save("initial commit", "")
+	// overwrite the name with a new version
+	curr := save("second commit", prev)
+	if prev == curr {
+		t.Fatalf("expected save to create a new version")
+	}
+
+	res, err := r.GC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Removed) != 1 || res.Removed[0] != prev {
+		t.Errorf("expected previous version %q to be removed, got: %v", prev, res.Removed)
+	}
+
+	if has, err := r.Store().Has(ctx, prev); err != nil {
+		t.Fatal(err)
+	} else if has {
+		t.Errorf("expected unreferenced version to be removed from the store")
+	}
+	if has, err := r.Store().Has(ctx, curr); err != nil {
+		t.Fatal(err)
+	} else if !has {
+		t.Errorf("expected current version to survive garbage collection")
+	}
+
+	// collecting again removes nothing
+	if res, err = r.GC(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Removed) != 0 {
+		t.Errorf("expected second collection to remove nothing, got: %v", res.Removed)
+	}
+}
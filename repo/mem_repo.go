@@ -89,6 +89,10 @@ func (r *MemRepo) ResolveRef(ctx context.Context, ref *dsref.Ref) (string, error
 		return "", dsref.ErrRefNotFound
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	// TODO (b5) - not totally sure why, but memRepo doesn't seem to be wiring up
 	// dscache correctly in in tests
 	// if r.dscache != nil {
@@ -126,6 +130,11 @@ func (r *MemRepo) Dscache() *dscache.Dscache {
 	return r.dscache
 }
 
+// GC removes unreferenced dataset versions from the store, see CollectGarbage
+func (r *MemRepo) GC(ctx context.Context) (*GCResult, error) {
+	return CollectGarbage(ctx, r)
+}
+
 // RemoveLogbook drops a MemRepo's logbook pointer. MemRepo gets used in tests
 // a bunch, where logbook manipulation is helpful
 func (r *MemRepo) RemoveLogbook() {
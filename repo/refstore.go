@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/qri-io/qri/dsref"
@@ -26,6 +27,19 @@ type Refstore interface {
 	RefCount() (int, error)
 }
 
+// AliasStore is an optional extension to Refstore for stores that support
+// aliases. An alias is a human-friendly "peername/name" string that resolves
+// to another dataset, following the target as it updates. Aliases can't point
+// to other aliases. Getting an alias whose target has been deleted returns
+// ErrDanglingAlias
+type AliasStore interface {
+	// PutAlias makes alias resolve to target. target must exist, and alias
+	// must not name an existing dataset
+	PutAlias(ctx context.Context, alias, target string) error
+	// DeleteAlias removes an alias
+	DeleteAlias(ctx context.Context, alias string) error
+}
+
 // ListVersionInfoShim wraps a call to References, converting results to a
 // slice of VersionInfos
 func ListVersionInfoShim(r Repo, offset, limit int) ([]dsref.VersionInfo, error) {
@@ -4,6 +4,7 @@
 package repo
 
 import (
+	"context"
 	"fmt"
 
 	golog "github.com/ipfs/go-log"
@@ -49,6 +50,9 @@ var (
 	ErrNoRegistry = fmt.Errorf("no configured registry")
 	// ErrEmptyRef indicates that the given reference is empty
 	ErrEmptyRef = fmt.Errorf("repo: empty dataset reference")
+	// ErrDanglingAlias is for when an alias points to a dataset that doesn't
+	// exist
+	ErrDanglingAlias = fmt.Errorf("repo: alias target doesn't exist")
 )
 
 // Repo is the interface for working with a qri repository qri repos are stored
@@ -98,6 +102,10 @@ type Repo interface {
 	// Decsisions regarding retentaion of peers is left to the the implementation
 	Profiles() profile.Store
 
+	// GC removes dataset versions no name or logbook head points to from the
+	// repo's store
+	GC(ctx context.Context) (*GCResult, error)
+
 	// Done returns a channel that the repo will send on when the repo is closed
 	Done() <-chan struct{}
 	// DoneErr gives any error that occured in the shutdown process